@@ -0,0 +1,40 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package api_monitoring
+
+import (
+	"encoding/json"
+	"net/http"
+	"scheduler/errors"
+	"scheduler/log"
+	"scheduler/queue"
+	"scheduler/utils"
+)
+
+// Retrieve the fill of the local queue and of its sub-queues, with their weights and caps.
+func QueueStatus(w http.ResponseWriter, r *http.Request) {
+	res, err := json.Marshal(queue.GetStatus())
+	if err != nil {
+		log.Log.Errorf("Cannot encode queue status to json")
+		errors.ReplyWithError(w, errors.GenericError)
+		return
+	}
+
+	utils.SendJSONResponse(&w, 200, string(res))
+}
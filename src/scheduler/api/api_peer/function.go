@@ -29,9 +29,11 @@ import (
 	"scheduler/errors"
 	"scheduler/log"
 	"scheduler/memdb"
+	"scheduler/queue"
 	"scheduler/scheduler"
 	"scheduler/types"
 	"scheduler/utils"
+	"time"
 )
 
 // Execute a function. This function must called only by another node, and not a client.
@@ -73,13 +75,22 @@ func FunctionExecute(w http.ResponseWriter, r *http.Request) {
 		ServiceName:        function,
 		Payload:            []byte(peerRequest.Payload), // the payload is a string because request it's a peer request
 		ContentType:        peerRequest.ContentType,
+		Key:                peerRequest.Key,
+		Priority:           peerRequest.Priority,
+		Tenant:             peerRequest.Tenant,
+	}
+
+	// the deadline is relative to the forward, it may be already expired
+	if peerRequest.Deadline != 0 {
+		deadline := time.Now().Add(time.Duration(peerRequest.Deadline * float64(time.Second)))
+		req.Deadline = &deadline
 	}
 
 	log.Log.Debugf("[R#%d] type=%s, len(payload)=%d", requestId, req.ContentType, len(req.Payload))
 	log.Log.Debugf("[R#%d] len(peers)=%d, service=%s", requestId, len(peerRequest.PeersList), req.ServiceName)
 
 	// schedule the job
-	job, err := scheduler.Schedule(&req)
+	job, err := scheduler.Schedule(r.Context(), &req)
 	// prepare response
 	res := preparePeerResponse(&peerRequest, job, err)
 	responseBodyBytes, err := json.Marshal(res)
@@ -122,6 +133,14 @@ func preparePeerResponse(peerRequest *types.PeerJobRequest, jobResult *scheduler
 			Body:       jobBodyResponse,
 			StatusCode: 500,
 		}
+		// the job waited too long in the queue of this node
+		if _, expired := scheduleErr.(queue.ErrorExpired); expired {
+			res.StatusCode = 504
+		}
+		// the sub-queue of the tenant or the function of the job is at its cap on this node
+		if _, subQueueFull := scheduleErr.(queue.ErrorSubQueueFull); subQueueFull {
+			res.StatusCode = 503
+		}
 	}
 
 	return &res
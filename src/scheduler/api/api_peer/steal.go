@@ -0,0 +1,61 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package api_peer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"scheduler/errors"
+	"scheduler/log"
+	"scheduler/scheduler"
+	"scheduler/types"
+	"scheduler/utils"
+)
+
+// Hand over to an idle node the jobs waiting in the queue. This function must called only by another node, and not a
+// client. The jobs are sent to the node as function executions and the reply only tells how many they are.
+func StealJobs(w http.ResponseWriter, r *http.Request) {
+	bytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Log.Debugf("Cannot parse input: %s", err)
+		errors.ReplyWithError(w, errors.InputNotValid)
+		return
+	}
+
+	var stealRequest types.PeerStealRequest
+	err = json.Unmarshal(bytes, &stealRequest)
+	if err != nil {
+		log.Log.Debugf("Cannot parse json input: %s", err)
+		errors.ReplyWithError(w, errors.InputNotValid)
+		return
+	}
+	if stealRequest.MachineIp == "" {
+		errors.ReplyWithErrorMessage(w, errors.InputNotValid, "machine_ip is required")
+		return
+	}
+
+	res := types.PeerStealResponse{Jobs: scheduler.HandOverJobs(stealRequest.MachineIp, stealRequest.Slots)}
+	if res.Jobs > 0 {
+		log.Log.Debugf("Handed over %d jobs to %s", res.Jobs, stealRequest.MachineIp)
+	}
+
+	resBytes, _ := json.Marshal(res)
+	utils.SendJSONResponse(&w, 200, string(resBytes))
+}
@@ -20,6 +20,7 @@ package api
 
 import (
 	"encoding/json"
+	"github.com/gorilla/mux"
 	"io/ioutil"
 	"net/http"
 	"scheduler/config"
@@ -28,8 +29,11 @@ import (
 	"scheduler/scheduler"
 	"scheduler/types"
 	"scheduler/utils"
+	"time"
 )
 
+const SetSchedulerModeDrain = "drain"
+
 // Retrieve the current configuration of the system.
 func GetConfiguration(w http.ResponseWriter, r *http.Request) {
 	configuration, err := json.Marshal(config.Configuration.GetConfiguration())
@@ -91,7 +95,23 @@ func GetScheduler(w http.ResponseWriter, r *http.Request) {
 	utils.SendJSONResponse(&w, 200, string(sched))
 }
 
+// Retrieve the list of the available schedulers with the documentation of their parameters.
+func GetSchedulers(w http.ResponseWriter, r *http.Request) {
+	schedulers, err := json.Marshal(scheduler.GetAvailableSchedulers())
+	if err != nil {
+		log.Log.Errorf("Cannot encode available schedulers to json")
+		errors.ReplyWithError(w, errors.GenericError)
+		return
+	}
+
+	utils.SendJSONResponse(&w, 200, string(schedulers))
+}
+
 // Set the scheduler information and save the configuration to file in such a way it is loaded automatically at startup.
+// With the query parameter mode=drain the scheduler is swapped even if functions are running: new requests go to the new
+// scheduler while in-flight ones complete on the old one. In this case the optional timeout query parameter (e.g. 30s)
+// tells how long to wait for the old scheduler to drain before replying, the reply is the swap status and it is 200 if
+// the old scheduler is drained or 202 if it is still draining.
 func SetScheduler(w http.ResponseWriter, r *http.Request) {
 	var proposedScheduler = types.SchedulerDescriptor{}
 	reqBody, _ := ioutil.ReadAll(r.Body)
@@ -103,10 +123,30 @@ func SetScheduler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = scheduler.SetScheduler(&proposedScheduler)
+	drain := r.URL.Query().Get("mode") == SetSchedulerModeDrain
+	var timeout time.Duration
+	if timeoutParam := r.URL.Query().Get("timeout"); timeoutParam != "" {
+		timeout, err = time.ParseDuration(timeoutParam)
+		if err != nil || timeout < 0 {
+			log.Log.Errorf("Cannot parse passed timeout %s", timeoutParam)
+			errors.ReplyWithErrorMessage(w, errors.InputNotValid, "timeout is not a valid duration")
+			return
+		}
+	}
+
+	if drain {
+		err = scheduler.SwapScheduler(&proposedScheduler, timeout)
+	} else {
+		err = scheduler.SetScheduler(&proposedScheduler)
+	}
 	if err != nil {
 		log.Log.Errorf("Cannot set new scheduler: %s", err.Error())
-		errors.ReplyWithErrorMessage(w, errors.GenericError, err.Error())
+		switch err.(type) {
+		case scheduler.UnknownScheduler, scheduler.BadSchedulerParameters:
+			errors.ReplyWithErrorMessage(w, errors.InputNotValid, err.Error())
+		default:
+			errors.ReplyWithErrorMessage(w, errors.GenericError, err.Error())
+		}
 		return
 	}
 
@@ -118,5 +158,169 @@ func SetScheduler(w http.ResponseWriter, r *http.Request) {
 
 	log.Log.Infof("Configuration updated with scheduler: %s", scheduler.GetName())
 
+	if drain {
+		drained := scheduler.WaitSwapDrained(timeout)
+		status, _ := json.Marshal(scheduler.GetSwapStatus())
+		if drained {
+			utils.SendJSONResponse(&w, 200, string(status))
+		} else {
+			utils.SendJSONResponse(&w, 202, string(status))
+		}
+		return
+	}
+
+	w.WriteHeader(200)
+}
+
+// Retrieve the scheduler used for the jobs of a function, that is the global one if the function has not its own.
+func GetFunctionScheduler(w http.ResponseWriter, r *http.Request) {
+	function := mux.Vars(r)["function"]
+
+	functionScheduler := types.FunctionScheduler{
+		Function:  function,
+		Scheduler: scheduler.GetFunctionScheduler(function),
+	}
+	if functionScheduler.Scheduler == nil {
+		functionScheduler.Scheduler = scheduler.GetScheduler()
+		functionScheduler.Default = true
+	}
+
+	res, err := json.Marshal(functionScheduler)
+	if err != nil {
+		log.Log.Errorf("Cannot encode function scheduler to json")
+		errors.ReplyWithError(w, errors.GenericError)
+		return
+	}
+
+	utils.SendJSONResponse(&w, 200, string(res))
+}
+
+// Set the scheduler used for the jobs of a function in place of the global one, and save the schedulers of all the
+// functions to file in such a way they are loaded automatically at startup. A function named "swap" cannot have its own
+// scheduler, since its path is taken by the swap status API.
+func SetFunctionScheduler(w http.ResponseWriter, r *http.Request) {
+	function := mux.Vars(r)["function"]
+	if function == "swap" {
+		errors.ReplyWithErrorMessage(w, errors.InputNotValid, "The scheduler of a function named swap cannot be set")
+		return
+	}
+	var proposedScheduler = types.SchedulerDescriptor{}
+	reqBody, _ := ioutil.ReadAll(r.Body)
+
+	err := json.Unmarshal(reqBody, &proposedScheduler)
+	if err != nil {
+		log.Log.Errorf("Cannot decode passed configuration: %s", err.Error())
+		errors.ReplyWithError(w, errors.InputNotValid)
+		return
+	}
+
+	err = scheduler.SetFunctionScheduler(function, &proposedScheduler)
+	if err != nil {
+		log.Log.Errorf("Cannot set new scheduler for function %s: %s", function, err.Error())
+		switch err.(type) {
+		case scheduler.UnknownScheduler, scheduler.BadSchedulerParameters:
+			errors.ReplyWithErrorMessage(w, errors.InputNotValid, err.Error())
+		default:
+			errors.ReplyWithErrorMessage(w, errors.GenericError, err.Error())
+		}
+		return
+	}
+
+	saveFunctionSchedulers()
+
+	log.Log.Infof("Configuration of function %s updated with scheduler: %s", function, scheduler.GetNameForFunction(function))
+
+	w.WriteHeader(200)
+}
+
+// Remove the scheduler of a function, which will use the global one from now on.
+func DeleteFunctionScheduler(w http.ResponseWriter, r *http.Request) {
+	function := mux.Vars(r)["function"]
+
+	if !scheduler.RemoveFunctionScheduler(function) {
+		errors.ReplyWithErrorMessage(w, errors.GenericNotFoundError, "Function has no scheduler of its own")
+		return
+	}
+
+	saveFunctionSchedulers()
+
+	log.Log.Infof("Configuration of function %s updated with global scheduler", function)
+
+	w.WriteHeader(200)
+}
+
+// Retrieve the status of the last drain-and-swap of the scheduler.
+func GetSchedulerSwap(w http.ResponseWriter, r *http.Request) {
+	status := scheduler.GetSwapStatus()
+	if status == nil {
+		errors.ReplyWithErrorMessage(w, errors.GenericNotFoundError, "Scheduler has never been swapped")
+		return
+	}
+
+	res, err := json.Marshal(status)
+	if err != nil {
+		log.Log.Errorf("Cannot encode swap status to json")
+		errors.ReplyWithError(w, errors.GenericError)
+		return
+	}
+
+	utils.SendJSONResponse(&w, 200, string(res))
+}
+
+// Retrieve the table learned by the scheduler of a function, passed as "function" query parameter, or by the global
+// scheduler if no function is passed.
+func GetLearningTable(w http.ResponseWriter, r *http.Request) {
+	function := r.URL.Query().Get("function")
+
+	table, err := scheduler.GetLearningTable(function)
+	if err != nil {
+		errors.ReplyWithErrorMessage(w, errors.GenericNotFoundError, err.Error())
+		return
+	}
+
+	res, err := json.Marshal(table)
+	if err != nil {
+		log.Log.Errorf("Cannot encode learning table to json")
+		errors.ReplyWithError(w, errors.GenericError)
+		return
+	}
+
+	utils.SendJSONResponse(&w, 200, string(res))
+}
+
+// Replace the table learned by the scheduler of a function, passed as "function" query parameter, or by the global
+// scheduler if no function is passed. This allows to warm-start a scheduler with a table learned elsewhere.
+func SetLearningTable(w http.ResponseWriter, r *http.Request) {
+	function := r.URL.Query().Get("function")
+	var table = types.LearningTable{}
+	reqBody, _ := ioutil.ReadAll(r.Body)
+
+	err := json.Unmarshal(reqBody, &table)
+	if err != nil {
+		log.Log.Errorf("Cannot decode passed learning table: %s", err.Error())
+		errors.ReplyWithError(w, errors.InputNotValid)
+		return
+	}
+
+	err = scheduler.SetLearningTable(function, &table)
+	if err != nil {
+		log.Log.Errorf("Cannot set learning table: %s", err.Error())
+		errors.ReplyWithErrorMessage(w, errors.InputNotValid, err.Error())
+		return
+	}
+
+	log.Log.Infof("Learning table of scheduler %s replaced with %d states", scheduler.GetNameForFunction(function), len(table.Entries))
+
 	w.WriteHeader(200)
 }
+
+/*
+ * Utils
+ */
+
+func saveFunctionSchedulers() {
+	err := config.SaveConfigurationFunctionsSchedulerToConfigFile(scheduler.GetFunctionSchedulers())
+	if err != nil {
+		log.Log.Errorf("Cannot save configuration to file %s", config.GetConfigFunctionsSchedulerFilePath())
+	}
+}
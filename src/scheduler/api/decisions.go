@@ -0,0 +1,79 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"scheduler/errors"
+	"scheduler/log"
+	"scheduler/scheduler"
+	"scheduler/types"
+	"scheduler/utils"
+	"strconv"
+)
+
+// Retrieve the last scheduling decision traces, from the oldest. They can be filtered by the "function" and "request"
+// query parameters, and "limit" returns only the last ones.
+func GetDecisions(w http.ResponseWriter, r *http.Request) {
+	function := r.URL.Query().Get("function")
+
+	var requestId *uint64
+	if requestParam := r.URL.Query().Get("request"); requestParam != "" {
+		id, err := strconv.ParseUint(requestParam, 10, 64)
+		if err != nil {
+			errors.ReplyWithErrorMessage(w, errors.InputNotValid, "request must be a request id")
+			return
+		}
+		requestId = &id
+	}
+
+	limit := 0
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		var err error
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 0 {
+			errors.ReplyWithErrorMessage(w, errors.InputNotValid, "limit must be a positive number")
+			return
+		}
+	}
+
+	traces := []*types.DecisionTrace{}
+	for _, trace := range scheduler.GetDecisionTraces() {
+		if function != "" && trace.Function != function {
+			continue
+		}
+		if requestId != nil && trace.RequestId != *requestId {
+			continue
+		}
+		traces = append(traces, trace)
+	}
+	if limit > 0 && len(traces) > limit {
+		traces = traces[len(traces)-limit:]
+	}
+
+	res, err := json.Marshal(traces)
+	if err != nil {
+		log.Log.Errorf("Cannot encode decision traces to json")
+		errors.ReplyWithError(w, errors.GenericError)
+		return
+	}
+
+	utils.SendJSONResponse(&w, 200, string(res))
+}
@@ -29,9 +29,12 @@ import (
 	"scheduler/log"
 	"scheduler/memdb"
 	"scheduler/metrics"
+	"scheduler/queue"
 	"scheduler/scheduler"
 	"scheduler/types"
 	"scheduler/utils"
+	"strconv"
+	"time"
 )
 
 func FunctionPost(w http.ResponseWriter, r *http.Request) {
@@ -70,16 +73,63 @@ func executeFunction(w http.ResponseWriter, r *http.Request) {
 		Payload:     payload,
 		ContentType: r.Header.Get("Content-Type"),
 		External:    false,
+		Key:         r.Header.Get(HeaderP2PFaaSKey),
+		Tenant:      r.Header.Get(HeaderP2PFaaSTenant),
+		Headers:     r.Header,
+	}
+
+	// the deadline of the request overrides the one of the function
+	if deadlineHeader := r.Header.Get(HeaderP2PFaaSDeadline); deadlineHeader != "" {
+		deadline, err := parseDeadlineHeader(deadlineHeader)
+		if err != nil {
+			errors.ReplyWithErrorMessage(w, errors.InputNotValid, err.Error())
+			log.Log.Debugf("[R#%d] Bad deadline: %s", requestId, err.Error())
+			return
+		}
+		requestDeadline := time.Now().Add(deadline)
+		req.Deadline = &requestDeadline
+	}
+
+	// the priority of the request overrides the one of the function
+	req.Priority = memdb.GetFunctionPriority(function)
+	if priorityHeader := r.Header.Get(HeaderP2PFaaSPriority); priorityHeader != "" {
+		priority, err := types.ParsePriority(priorityHeader)
+		if err != nil {
+			errors.ReplyWithErrorMessage(w, errors.InputNotValid, err.Error())
+			log.Log.Debugf("[R#%d] Bad priority: %s", requestId, err.Error())
+			return
+		}
+		req.Priority = priority
 	}
 
 	// schedule the function execution
-	jobResult, err := scheduler.Schedule(&req)
+	jobResult, err := scheduler.Schedule(r.Context(), &req)
 
 	/* This is blocking */
 
+	// the trace is returned also when the job cannot be scheduled
+	if traceRequested, _ := strconv.ParseBool(r.Header.Get(HeaderP2PFaaSTrace)); traceRequested {
+		addTraceHeader(&req, w)
+	}
+
 	// check if any error
 	if err != nil {
-		if cannotScheduleError, ok := err.(*scheduler.JobCannotBeScheduled); ok {
+		if deadlineError, ok := err.(scheduler.JobDeadlineCannotBeMet); ok {
+			errors.ReplyWithErrorMessage(w, errors.JobDeadlineCannotBeMetError, deadlineError.Error())
+			log.Log.Debugf("[R#%d] %s", requestId, deadlineError.Error())
+			return
+		}
+		if expiredError, ok := err.(queue.ErrorExpired); ok {
+			errors.ReplyWithErrorMessage(w, errors.JobQueueExpiredError, expiredError.Error())
+			log.Log.Debugf("[R#%d] %s", requestId, expiredError.Error())
+			return
+		}
+		if subQueueFullError, ok := err.(queue.ErrorSubQueueFull); ok {
+			errors.ReplyWithErrorMessage(w, errors.JobSubQueueFullError, subQueueFullError.Error())
+			log.Log.Debugf("[R#%d] %s", requestId, subQueueFullError.Error())
+			return
+		}
+		if cannotScheduleError, ok := err.(scheduler.JobCannotBeScheduled); ok {
 			errors.ReplyWithError(w, errors.JobCannotBeScheduledError)
 			log.Log.Debugf("[R#%d] %s", requestId, cannotScheduleError.Error())
 			return
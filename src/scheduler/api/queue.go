@@ -0,0 +1,65 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"scheduler/errors"
+	"scheduler/log"
+	"scheduler/queue"
+	"scheduler/types"
+	"scheduler/utils"
+)
+
+// Retrieve how the local queue is split in sub-queues, with their weights and caps.
+func GetQueueConfiguration(w http.ResponseWriter, r *http.Request) {
+	res, err := json.Marshal(queue.GetConfiguration())
+	if err != nil {
+		log.Log.Errorf("Cannot encode queue configuration to json")
+		errors.ReplyWithError(w, errors.GenericError)
+		return
+	}
+
+	utils.SendJSONResponse(&w, 200, string(res))
+}
+
+// Set how the local queue is split in sub-queues, by function or by tenant, and their weights and caps. Sub-queues are
+// served by deficit round-robin, so that a single function or tenant cannot starve the others.
+func SetQueueConfiguration(w http.ResponseWriter, r *http.Request) {
+	var conf types.QueueConfiguration
+	reqBody, _ := ioutil.ReadAll(r.Body)
+
+	err := json.Unmarshal(reqBody, &conf)
+	if err != nil {
+		log.Log.Errorf("Cannot decode passed queue configuration: %s", err.Error())
+		errors.ReplyWithError(w, errors.InputNotValid)
+		return
+	}
+
+	err = queue.SetConfiguration(conf)
+	if err != nil {
+		log.Log.Errorf("Cannot set queue configuration: %s", err.Error())
+		errors.ReplyWithErrorMessage(w, errors.InputNotValid, err.Error())
+		return
+	}
+
+	w.WriteHeader(200)
+}
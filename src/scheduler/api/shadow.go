@@ -0,0 +1,118 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"scheduler/errors"
+	"scheduler/log"
+	"scheduler/scheduler"
+	"scheduler/types"
+	"scheduler/utils"
+	"strconv"
+)
+
+// Retrieve the scheduler evaluated in shadow mode, with the number of decisions in which it agrees with the active one.
+func GetShadowScheduler(w http.ResponseWriter, r *http.Request) {
+	status := scheduler.GetShadowStatus()
+	if status == nil {
+		errors.ReplyWithErrorMessage(w, errors.GenericNotFoundError, "No shadow scheduler is set")
+		return
+	}
+
+	res, err := json.Marshal(status)
+	if err != nil {
+		log.Log.Errorf("Cannot encode shadow status to json")
+		errors.ReplyWithError(w, errors.GenericError)
+		return
+	}
+
+	utils.SendJSONResponse(&w, 200, string(res))
+}
+
+// Set the scheduler evaluated in shadow mode: it decides every job next to the active one but it never executes them.
+// It does not probe peers, using their last known loads, unless the "probe" query parameter is true.
+func SetShadowScheduler(w http.ResponseWriter, r *http.Request) {
+	var proposedScheduler = types.SchedulerDescriptor{}
+	reqBody, _ := ioutil.ReadAll(r.Body)
+
+	err := json.Unmarshal(reqBody, &proposedScheduler)
+	if err != nil {
+		log.Log.Errorf("Cannot decode passed configuration: %s", err.Error())
+		errors.ReplyWithError(w, errors.InputNotValid)
+		return
+	}
+
+	probe := false
+	if probeParam := r.URL.Query().Get("probe"); probeParam != "" {
+		probe, err = strconv.ParseBool(probeParam)
+		if err != nil {
+			errors.ReplyWithErrorMessage(w, errors.InputNotValid, "probe must be true or false")
+			return
+		}
+	}
+
+	err = scheduler.SetShadowScheduler(&proposedScheduler, probe)
+	if err != nil {
+		log.Log.Errorf("Cannot set shadow scheduler: %s", err.Error())
+		switch err.(type) {
+		case scheduler.UnknownScheduler, scheduler.BadSchedulerParameters:
+			errors.ReplyWithErrorMessage(w, errors.InputNotValid, err.Error())
+		default:
+			errors.ReplyWithErrorMessage(w, errors.GenericError, err.Error())
+		}
+		return
+	}
+
+	log.Log.Infof("Shadow scheduler set to %s, probe=%t", proposedScheduler.Name, probe)
+
+	w.WriteHeader(200)
+}
+
+// Stop the shadow evaluation.
+func DeleteShadowScheduler(w http.ResponseWriter, r *http.Request) {
+	if !scheduler.RemoveShadowScheduler() {
+		errors.ReplyWithErrorMessage(w, errors.GenericNotFoundError, "No shadow scheduler is set")
+		return
+	}
+
+	log.Log.Infof("Shadow scheduler removed")
+
+	w.WriteHeader(200)
+}
+
+// Retrieve the last decisions taken for the same jobs by the active and the shadow schedulers, from the oldest.
+func GetShadowDecisions(w http.ResponseWriter, r *http.Request) {
+	decisions := scheduler.GetShadowDecisions()
+	if decisions == nil {
+		errors.ReplyWithErrorMessage(w, errors.GenericNotFoundError, "No shadow scheduler is set")
+		return
+	}
+
+	res, err := json.Marshal(decisions)
+	if err != nil {
+		log.Log.Errorf("Cannot encode shadow decisions to json")
+		errors.ReplyWithError(w, errors.GenericError)
+		return
+	}
+
+	utils.SendJSONResponse(&w, 200, string(res))
+}
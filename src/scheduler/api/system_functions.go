@@ -24,7 +24,10 @@ import (
 	"scheduler/errors"
 	"scheduler/faas"
 	"scheduler/log"
+	"scheduler/memdb"
+	"scheduler/types"
 	"scheduler/utils"
+	"time"
 )
 
 func SystemFunctionsGet(w http.ResponseWriter, r *http.Request) {
@@ -44,12 +47,34 @@ func SystemFunctionsPost(w http.ResponseWriter, r *http.Request) {
 	var service faas.Service
 	_ = json.NewDecoder(r.Body).Decode(&service)
 
+	priority := types.PriorityNormal
+	if service.Priority != "" {
+		var err error
+		if priority, err = types.ParsePriority(service.Priority); err != nil {
+			errors.ReplyWithErrorMessage(w, errors.InputNotValid, err.Error())
+			return
+		}
+	}
+
 	res, err := faas.FunctionDeploy(service.OpenFaaSFunction)
 	if err != nil {
 		errors.ReplyWithError(w, errors.GenericDeployError)
 		return
 	}
 
+	// the deadline of the service is in milliseconds and it is used by deadline-aware schedulers
+	if res.StatusCode < 300 && service.Deadline > 0 {
+		memdb.SetFunctionDeadline(service.OpenFaaSFunction.Service, time.Duration(service.Deadline)*time.Millisecond)
+	}
+	// the queue wait of the service is in milliseconds and it overrides the one of the configuration
+	if res.StatusCode < 300 && service.QueueWaitMax > 0 {
+		memdb.SetFunctionQueueWaitMax(service.OpenFaaSFunction.Service, time.Duration(service.QueueWaitMax)*time.Millisecond)
+	}
+	// the priority is applied to the jobs of the function which do not specify one
+	if res.StatusCode < 300 && service.Priority != "" {
+		memdb.SetFunctionPriority(service.OpenFaaSFunction.Service, priority)
+	}
+
 	utils.SendJSONResponseByte(&w, res.StatusCode, res.Body)
 
 	log.Log.Debugf("success")
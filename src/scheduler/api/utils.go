@@ -26,6 +26,8 @@ import (
 	"scheduler/log"
 	"scheduler/scheduler"
 	"scheduler/types"
+	"strconv"
+	"time"
 )
 
 const HeaderP2PFaaSVersion = "X-P2PFaaS-Version"
@@ -36,6 +38,17 @@ const HeaderP2PFaaSProbingTime = "X-P2PFaaS-Timing-Probing-Time-Seconds"
 const HeaderP2PFaaSSchedulingTime = "X-P2PFaaS-Timing-Scheduling-Time-Seconds"
 const HeaderP2PFaaSExternallyExecuted = "X-P2PFaaS-Externally-Executed"
 const HeaderP2PFaaSHops = "X-P2PFaaS-Hops"
+const HeaderP2PFaaSProbeMessages = "X-P2PFaaS-Timing-Probe-Messages"
+const HeaderP2PFaaSDeadline = "X-P2PFaaS-Deadline"
+const HeaderP2PFaaSDeadlineMet = "X-P2PFaaS-Deadline-Met"
+const HeaderP2PFaaSKey = "X-P2PFaaS-Key"
+const HeaderP2PFaaSPriority = "X-P2PFaaS-Priority"
+const HeaderP2PFaaSTenant = "X-P2PFaaS-Tenant"
+const HeaderP2PFaaSTrace = "X-P2PFaaS-Trace"
+const HeaderP2PFaaSForwardRetries = "X-P2PFaaS-Forward-Retries"
+const HeaderP2PFaaSForwardFailedPeers = "X-P2PFaaS-Forward-Failed-Peers"
+const HeaderP2PFaaSForwardLocalFallback = "X-P2PFaaS-Forward-Local-Fallback"
+const HeaderP2PFaaSHedged = "X-P2PFaaS-Hedged"
 const HeaderP2PFaaSPeersListIp = "X-P2PFaaS-Peers-List-Ip"
 const HeaderP2PFaaSPeersListId = "X-P2PFaaS-Peers-List-Id"
 
@@ -67,11 +80,30 @@ func addExecuteFunctionCustomHeaders(req *types.ServiceRequest, toSendResponse *
 	}
 
 	(*toSendResponse).Header().Add(HeaderP2PFaaSVersion, config.Version)
-	(*toSendResponse).Header().Add(HeaderP2PFaaSScheduler, scheduler.GetName())
+	(*toSendResponse).Header().Add(HeaderP2PFaaSScheduler, scheduler.GetNameForFunction(req.ServiceName))
 
-	// PowerOfN Headers
-	if scheduler.GetName() == scheduler.PowerOfNSchedulerName {
-		(*toSendResponse).Header().Add("X-P2PFaaS-Timing-Probe-Messages", fmt.Sprintf("%d", job.ProbingMessages))
+	// Probing schedulers headers
+	if job.ProbingMessages > 0 {
+		(*toSendResponse).Header().Add(HeaderP2PFaaSProbeMessages, fmt.Sprintf("%d", job.ProbingMessages))
+	}
+
+	// Forwarding failures headers
+	if len(job.ForwardFailures) > 0 {
+		failedPeersJ, _ := json.Marshal(job.ForwardFailures)
+		(*toSendResponse).Header().Add(HeaderP2PFaaSForwardRetries, fmt.Sprintf("%d", len(job.ForwardFailures)))
+		(*toSendResponse).Header().Add(HeaderP2PFaaSForwardFailedPeers, string(failedPeersJ))
+	}
+	if job.LocalFallback {
+		(*toSendResponse).Header().Add(HeaderP2PFaaSForwardLocalFallback, "True")
+	}
+
+	if job.Hedged {
+		(*toSendResponse).Header().Add(HeaderP2PFaaSHedged, "True")
+	}
+
+	// Deadline headers, the job is completed now
+	if req.Deadline != nil {
+		(*toSendResponse).Header().Add(HeaderP2PFaaSDeadlineMet, formatBoolHeader(!time.Now().After(*req.Deadline)))
 	}
 
 	// job has been executed internally so we have single times
@@ -102,7 +134,7 @@ func addExecuteFunctionCustomHeaders(req *types.ServiceRequest, toSendResponse *
 		(*toSendResponse).Header().Add(HeaderP2PFaaSExternallyExecuted, "True")
 		(*toSendResponse).Header().Add(HeaderP2PFaaSHops, fmt.Sprintf("%d", hops))
 
-		if job.ExternalExecutionInfo.PeersList[0].Timings.ExecutionTime != nil {
+		if len(job.ExternalExecutionInfo.PeersList) > 0 && job.ExternalExecutionInfo.PeersList[0].Timings.ExecutionTime != nil {
 			(*toSendResponse).Header().Add(HeaderP2PFaaSExecutionTime, fmt.Sprintf("%f", *job.ExternalExecutionInfo.PeersList[0].Timings.ExecutionTime))
 		} else {
 			log.Log.Errorf("[R#%d] No execution time", req.Id)
@@ -151,3 +183,37 @@ func addExecuteFunctionCustomHeaders(req *types.ServiceRequest, toSendResponse *
 		(*toSendResponse).Header().Add(HeaderP2PFaaSSchedulingTimingsList, fmt.Sprintf("%s", string(schedulingTimesJ)))
 	}
 }
+
+// addTraceHeader adds the decision trace of the request, encoded in json
+func addTraceHeader(req *types.ServiceRequest, w http.ResponseWriter) {
+	if req.Trace == nil {
+		return
+	}
+	traceJ, err := json.Marshal(req.Trace)
+	if err != nil {
+		log.Log.Errorf("[R#%d] Cannot encode decision trace to json", req.Id)
+		return
+	}
+	w.Header().Set(HeaderP2PFaaSTrace, string(traceJ))
+}
+
+// parseDeadlineHeader parses the deadline of a request, given in seconds or as a duration like "500ms"
+func parseDeadlineHeader(value string) (time.Duration, error) {
+	var deadline time.Duration
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		deadline = time.Duration(seconds * float64(time.Second))
+	} else if deadline, err = time.ParseDuration(value); err != nil {
+		return 0, fmt.Errorf("%s must be in seconds or a duration: %s", HeaderP2PFaaSDeadline, value)
+	}
+	if deadline <= 0 {
+		return 0, fmt.Errorf("%s must be positive: %s", HeaderP2PFaaSDeadline, value)
+	}
+	return deadline, nil
+}
+
+func formatBoolHeader(value bool) string {
+	if value {
+		return "True"
+	}
+	return "False"
+}
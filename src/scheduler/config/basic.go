@@ -32,12 +32,14 @@ const DataPath = "/data"
 // const ConfigurationFilePath = "/config"
 const ConfigurationFileName = "p2p_faas-scheduler.json"
 const ConfigurationSchedulerFileName = "p2p_faas-scheduler-config.json"
+const ConfigurationFunctionsSchedulerFileName = "p2p_faas-scheduler-functions-config.json"
 
 // const ConfigurationFileFullPath = ConfigurationFilePath + "/" + ConfigurationFileName
 // const SchedulerConfigurationFullPath = ConfigurationFilePath + "/" + SchedulerConfigurationFileName
 
 const DefaultListeningPort = 18080
 const DefaultQueueLengthMax = 100
+const DefaultQueueWaitMax = 0 // milliseconds, no limit
 const DefaultFunctionsRunningMax = 10
 
 // env
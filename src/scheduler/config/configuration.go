@@ -34,6 +34,7 @@ func (ConfigError) Error() string {
 type ConfigurationSet struct {
 	runningFunctionMax     uint
 	queueLengthMax         uint
+	queueWaitMax           uint
 	listeningPort          uint
 	openFaasListeningPort  uint
 	openFaasListeningHost  string
@@ -45,6 +46,7 @@ type ConfigurationSet struct {
 type ConfigurationSetExp struct {
 	RunningFunctionMax     uint   `json:"running_functions_max" bson:"running_functions_max"`
 	QueueLengthMax         uint   `json:"queue_length_max" bson:"queue_length_max"`
+	QueueWaitMax           uint   `json:"queue_wait_max" bson:"queue_wait_max"` // milliseconds, 0 for no limit
 	ListeningPort          uint   `json:"listening_port" bson:"listening_port"`
 	OpenFaasListeningPort  uint   `json:"faas_listening_port" bson:"faas_listening_port"`
 	OpenFaasListeningHost  string `json:"faas_listening_host" bson:"faas_listening_host"`
@@ -63,6 +65,9 @@ func (c ConfigurationSet) GetRunningFunctionMax() uint {
 func (c ConfigurationSet) GetQueueLengthMax() uint {
 	return c.queueLengthMax
 }
+func (c ConfigurationSet) GetQueueWaitMax() uint {
+	return c.queueWaitMax
+}
 func (c ConfigurationSet) GetListeningPort() uint {
 	return c.listeningPort
 }
@@ -102,6 +107,9 @@ func (c *ConfigurationSet) SetRunningFunctionMax(n uint) {
 func (c *ConfigurationSet) SetQueueLengthMax(n uint) {
 	c.queueLengthMax = n
 }
+func (c *ConfigurationSet) SetQueueWaitMax(n uint) {
+	c.queueWaitMax = n
+}
 func (c *ConfigurationSet) SetListeningPort(n uint) {
 	c.listeningPort = n
 }
@@ -164,6 +172,7 @@ func GetDefaultConfiguration() *ConfigurationSetExp {
 	return &ConfigurationSetExp{
 		RunningFunctionMax:     DefaultFunctionsRunningMax,
 		QueueLengthMax:         DefaultQueueLengthMax,
+		QueueWaitMax:           DefaultQueueWaitMax,
 		ListeningPort:          DefaultListeningPort,
 		OpenFaasListeningPort:  DefaultOpenFaaSListeningPort,
 		OpenFaasListeningHost:  DefaultOpenFaaSListeningHost,
@@ -176,6 +185,7 @@ func GetDefaultConfiguration() *ConfigurationSetExp {
 func copyAllFieldsToExp(from *ConfigurationSet, to *ConfigurationSetExp) {
 	to.RunningFunctionMax = from.runningFunctionMax
 	to.QueueLengthMax = from.queueLengthMax
+	to.QueueWaitMax = from.queueWaitMax
 	to.ListeningPort = from.listeningPort
 	to.OpenFaasListeningHost = from.openFaasListeningHost
 	to.OpenFaasListeningPort = from.openFaasListeningPort
@@ -187,6 +197,7 @@ func copyAllFieldsToExp(from *ConfigurationSet, to *ConfigurationSetExp) {
 func copyAllFieldsToUnExp(from *ConfigurationSetExp, to *ConfigurationSet) {
 	to.runningFunctionMax = from.RunningFunctionMax
 	to.queueLengthMax = from.QueueLengthMax
+	to.queueWaitMax = from.QueueWaitMax
 	to.listeningPort = from.ListeningPort
 	to.openFaasListeningHost = from.OpenFaasListeningHost
 	to.openFaasListeningPort = from.OpenFaasListeningPort
@@ -15,6 +15,10 @@ func GetConfigSchedulerFilePath() string {
 	return GetDataPath() + "/" + ConfigurationSchedulerFileName
 }
 
+func GetConfigFunctionsSchedulerFilePath() string {
+	return GetDataPath() + "/" + ConfigurationFunctionsSchedulerFileName
+}
+
 func SaveConfigurationToConfigFile() error {
 	// prepare configuration
 	confExported := GetDefaultConfiguration()
@@ -41,3 +45,18 @@ func SaveConfigurationSchedulerToConfigFile(descriptor *types.SchedulerDescripto
 
 	return nil
 }
+
+func SaveConfigurationFunctionsSchedulerToConfigFile(descriptors map[string]*types.SchedulerDescriptor) error {
+	configJson, err := json.MarshalIndent(descriptors, "", "  ")
+	if err != nil {
+		log.Log.Errorf("Cannot encode the schedulers of the functions: %s", err.Error())
+		return err
+	}
+	err = ioutil.WriteFile(GetConfigFunctionsSchedulerFilePath(), configJson, 0644)
+	if err != nil {
+		log.Log.Errorf("Cannot save configuration to file %s: %s", GetConfigFunctionsSchedulerFilePath(), err.Error())
+		return err
+	}
+
+	return nil
+}
@@ -18,7 +18,7 @@
 
 // Package discovery implements all functions that made possible the communication with the discovery service.
 //
-// The package when init checks if the discovery service is available for getting its configuration, if it is not then the
+// The package when started checks if the discovery service is available for getting its configuration, if it is not then the
 // scheduler is not started and the check is done every 5 seconds.
 package discovery
 
@@ -27,7 +27,8 @@ import (
 	"time"
 )
 
-func init() {
+// Start gets the configuration from the discovery service, it blocks until the service replies
+func Start() {
 	// try to get discovery configuration
 	log.Log.Debugf("Trying to get configuration from discovery service")
 	for ; ; {
@@ -42,7 +43,3 @@ func init() {
 		break
 	}
 }
-
-func Start() {
-
-}
@@ -18,8 +18,27 @@
 
 package discovery
 
+import (
+	"fmt"
+	"time"
+)
+
 type ErrorCannotGetServerList struct{}
 
 func (ErrorCannotGetServerList) Error() string {
 	return "Cannot get peers list"
 }
+
+type ErrorNoMachineWithinPing struct {
+	maxPing time.Duration
+}
+
+func (e ErrorNoMachineWithinPing) Error() string {
+	return fmt.Sprintf("No machine has a ping within %s", e.maxPing)
+}
+
+type ErrorNoMachineNotExcluded struct{}
+
+func (ErrorNoMachineNotExcluded) Error() string {
+	return "All the known machines are excluded"
+}
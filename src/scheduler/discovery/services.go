@@ -21,15 +21,35 @@ package discovery
 import (
 	"encoding/json"
 	"io/ioutil"
+	"math"
 	"math/rand"
 	"scheduler/log"
 	"scheduler/utils"
+	"sort"
 	"time"
 )
 
+// minPingWeight is the ping used for weighting machines with a lower (or not yet measured) ping
+const minPingWeight = 1 * time.Millisecond
+
 // getListOfServers get the list of known server by asking to the backend stack-service that is running in the same
 // machine of this service
 func GetListOfMachines() ([]string, error) {
+	machines, err := GetMachines()
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for _, machine := range machines {
+		values = append(values, machine.IP)
+	}
+
+	return values, nil
+}
+
+// GetMachines get the list of known machines with all their information, like ping and group
+func GetMachines() ([]Machine, error) {
 	// get the backend
 	res, err := utils.HttpGet(getListApiUrl())
 	if err != nil {
@@ -38,8 +58,7 @@ func GetListOfMachines() ([]string, error) {
 	}
 
 	var machines []Machine
-	var values []string
-	
+
 	response, _ := ioutil.ReadAll(res.Body)
 	_ = res.Body.Close()
 
@@ -49,15 +68,11 @@ func GetListOfMachines() ([]string, error) {
 		return nil, err
 	}
 
-	for _, machine := range machines {
-		values = append(values, machine.IP)
-	}
-
-	return values, nil
+	return machines, nil
 }
 
-// GetRandomServer returns a N different random servers from the list
-func GetNRandomMachines(n uint) ([]string, error) {
+// GetRandomServer returns a N different random servers from the list, excluding the passed ips
+func GetNRandomMachines(n uint, exclude []string) ([]string, error) {
 	if n == 0 {
 		return nil, nil
 	}
@@ -65,8 +80,12 @@ func GetNRandomMachines(n uint) ([]string, error) {
 	if err != nil || len(list) == 0 {
 		return nil, &ErrorCannotGetServerList{}
 	}
+	list = excludeMachines(list, exclude)
+	if len(list) == 0 {
+		return nil, &ErrorNoMachineNotExcluded{}
+	}
 	// if all machines are requested do not pick at random
-	if n == uint(len(list)) {
+	if n >= uint(len(list)) {
 		return list, nil
 	}
 
@@ -74,26 +93,87 @@ func GetNRandomMachines(n uint) ([]string, error) {
 	randomGenerator := rand.New(randomSource)
 
 	var out []string
-	var picked []int
-	for ; ; {
-		if len(picked) == int(n) {
-			break
+	for _, randomI := range randomGenerator.Perm(len(list))[:n] {
+		out = append(out, list[randomI])
+	}
+	return out, nil
+}
+
+// GetNRandomMachinesByPing returns N different random machines among the ones with a ping not greater than maxPing (if
+// maxPing is 0 all machines are considered). If weighted is true, machines are picked with a probability inversely
+// proportional to their ping, so that closer machines are preferred. Machines with the excluded ips are never picked.
+func GetNRandomMachinesByPing(n uint, maxPing time.Duration, weighted bool, exclude []string) ([]string, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	machines, err := GetMachines()
+	if err != nil || len(machines) == 0 {
+		return nil, &ErrorCannotGetServerList{}
+	}
+
+	var candidates []Machine
+	for _, machine := range machines {
+		if maxPing > 0 && machine.GetPing() > maxPing {
+			continue
 		}
+		if utils.StringInArray(machine.IP, exclude) {
+			continue
+		}
+		candidates = append(candidates, machine)
+	}
+	if len(candidates) == 0 && len(exclude) > 0 {
+		return nil, &ErrorNoMachineNotExcluded{}
+	}
+	if len(candidates) == 0 {
+		return nil, &ErrorNoMachineWithinPing{maxPing}
+	}
 
-		randomI := randomGenerator.Int() % len(list)
+	randomSource := rand.NewSource(time.Now().UnixNano())
+	randomGenerator := rand.New(randomSource)
 
-		for _, value := range picked {
-			if value == randomI {
-				continue
-			}
+	// weighted sampling without replacement: every machine gets the key u^(1/w) with u uniform in (0,1) and the
+	// machines with the greatest keys are picked, with all weights equal to 1 this is a uniform sampling
+	keys := make([]float64, len(candidates))
+	for i, machine := range candidates {
+		weight := 1.0
+		if weighted {
+			weight = 1.0 / math.Max(machine.GetPing().Seconds(), minPingWeight.Seconds())
 		}
+		keys[i] = math.Pow(randomGenerator.Float64(), 1.0/weight)
+	}
 
-		picked = append(picked, randomI)
-		out = append(out, list[randomI])
+	indexes := make([]int, len(candidates))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.Slice(indexes, func(i, j int) bool { return keys[indexes[i]] > keys[indexes[j]] })
+
+	var out []string
+	for i := 0; i < len(indexes) && i < int(n); i++ {
+		out = append(out, candidates[indexes[i]].IP)
 	}
 	return out, nil
 }
 
+// GetMachinesByGroup splits the ips of the known machines in the ones that belong to the passed group and the others
+func GetMachinesByGroup(group string) ([]string, []string, error) {
+	machines, err := GetMachines()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var inGroup []string
+	var others []string
+	for _, machine := range machines {
+		if machine.GroupName == group {
+			inGroup = append(inGroup, machine.IP)
+		} else {
+			others = append(others, machine.IP)
+		}
+	}
+	return inGroup, others, nil
+}
+
 func GetConfiguration() (*ServiceConfiguration, error) {
 	res, err := utils.HttpGet(getConfigurationApiUrl())
 	if err != nil {
@@ -18,6 +18,8 @@
 
 package discovery
 
+import "time"
+
 type ServiceConfiguration struct {
 	MachineIp       string   `json:"machine_ip" bson:"machine_ip"`
 	MachineId       string   `json:"machine_id" bson:"machine_id"`
@@ -41,3 +43,8 @@ type Machine struct {
 	// correctly
 	DeadPolls uint `json:"dead_polls" bson:"dead_polls"`
 }
+
+// GetPing returns the ping of the machine as a duration
+func (m Machine) GetPing() time.Duration {
+	return time.Duration(m.Ping * float64(time.Second))
+}
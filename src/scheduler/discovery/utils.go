@@ -18,7 +18,10 @@
 
 package discovery
 
-import "scheduler/types"
+import (
+	"scheduler/types"
+	"scheduler/utils"
+)
 
 // GetPeerDescriptor Generates the PeerListMember for the current node
 func GetPeerDescriptor(timings *types.Timings) types.PeersListMember {
@@ -33,3 +36,18 @@ func GetPeerDescriptor(timings *types.Timings) types.PeersListMember {
 
 	return peer
 }
+
+// excludeMachines returns the ips of the list which are not excluded
+func excludeMachines(list []string, exclude []string) []string {
+	if len(exclude) == 0 {
+		return list
+	}
+
+	var out []string
+	for _, ip := range list {
+		if !utils.StringInArray(ip, exclude) {
+			out = append(out, ip)
+		}
+	}
+	return out
+}
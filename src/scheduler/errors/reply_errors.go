@@ -42,7 +42,10 @@ const (
 	// openfaas
 	GenericOpenFaasError int = 300
 	// scheduler
-	JobCannotBeScheduledError int = 400
+	JobCannotBeScheduledError   int = 400
+	JobDeadlineCannotBeMetError int = 401
+	JobQueueExpiredError        int = 402
+	JobSubQueueFullError        int = 403
 	// mongo errors
 	DBDuplicateKey int = 11000
 )
@@ -61,6 +64,9 @@ var errorMessages = map[int]string{
 	300: "OpenFaas generic error, see logs",
 	// scheduler
 	400: "Job cannot be scheduled",
+	401: "Job deadline cannot be met",
+	402: "Job expired while waiting in the queue",
+	403: "Sub-queue of the job is full",
 	// mongo
 	11000: "A key is duplicated",
 }
@@ -79,6 +85,9 @@ var errorStatus = map[int]int{
 	300: 500,
 	// scheduler
 	400: 500,
+	401: 503,
+	402: 504,
+	403: 503,
 	// mongo
 	11000: 400,
 }
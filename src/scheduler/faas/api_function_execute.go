@@ -19,6 +19,7 @@
 package faas
 
 import (
+	"context"
 	"io/ioutil"
 	"scheduler/log"
 	"strconv"
@@ -26,8 +27,8 @@ import (
 
 var executeApiCallResponseHeaderDuration = "X-Duration-Seconds"
 
-func functionExecuteApiCall(host string, functionName string) (*APIResponse, error) {
-	res, err := HttpGet(GetApiFunctionUrl(host, functionName))
+func functionExecuteApiCall(ctx context.Context, host string, functionName string) (*APIResponse, error) {
+	res, err := HttpGetWithContext(ctx, GetApiFunctionUrl(host, functionName))
 	if err != nil {
 		log.Log.Debugf("Cannot create GET request to %s", err.Error(), GetApiFunctionUrl(host, functionName))
 		return nil, err
@@ -45,8 +46,8 @@ func functionExecuteApiCall(host string, functionName string) (*APIResponse, err
 	return &response, err
 }
 
-func functionExecutePostApiCall(host string, functionName string, payload []byte, contentType string) (*APIResponse, error) {
-	res, err := HttpPost(GetApiFunctionUrl(host, functionName), payload, contentType)
+func functionExecutePostApiCall(ctx context.Context, host string, functionName string, payload []byte, contentType string) (*APIResponse, error) {
+	res, err := HttpPostWithContext(ctx, GetApiFunctionUrl(host, functionName), payload, contentType)
 	if err != nil {
 		log.Log.Debugf("Cannot create POST request to %s", err.Error(), GetApiFunctionUrl(host, functionName))
 		return nil, err
@@ -21,6 +21,7 @@ package faas
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"net"
@@ -107,7 +108,12 @@ func HttpPostJSON(url string, json string) (*http.Response, error) {
 }
 
 func HttpGet(url string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", url, nil)
+	return HttpGetWithContext(context.Background(), url)
+}
+
+// HttpGetWithContext is like HttpGet but the request is aborted when the context is done
+func HttpGetWithContext(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, ErrorHttpCannotCreateRequest{}
 	}
@@ -124,7 +130,12 @@ func HttpGet(url string) (*http.Response, error) {
 }
 
 func HttpPost(url string, payload []byte, contentType string) (*http.Response, error) {
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
+	return HttpPostWithContext(context.Background(), url, payload, contentType)
+}
+
+// HttpPostWithContext is like HttpPost but the request is aborted when the context is done
+func HttpPostWithContext(ctx context.Context, url string, payload []byte, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(payload))
 	if err != nil {
 		return nil, ErrorHttpCannotCreateRequest{}
 	}
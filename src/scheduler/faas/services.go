@@ -18,7 +18,10 @@
 
 package faas
 
-import "scheduler/config"
+import (
+	"context"
+	"scheduler/config"
+)
 
 func FunctionsGet() ([]Function, *APIResponse, error) {
 	return GenFunctionsGet(config.Configuration.GetOpenFaasListeningHost())
@@ -32,8 +35,8 @@ func FunctionDeploy(function Function) (*APIResponse, error) {
 	return GenFunctionDeploy(config.Configuration.GetOpenFaasListeningHost(), function)
 }
 
-func FunctionExecute(functionName string, payload []byte, contentType string) (*APIResponse, error) {
-	return GenFunctionExecute(config.Configuration.GetOpenFaasListeningHost(), functionName, payload, contentType)
+func FunctionExecute(ctx context.Context, functionName string, payload []byte, contentType string) (*APIResponse, error) {
+	return GenFunctionExecute(ctx, config.Configuration.GetOpenFaasListeningHost(), functionName, payload, contentType)
 }
 
 func FunctionScale(functionName string, replicas uint) (*APIResponse, error) {
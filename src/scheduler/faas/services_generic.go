@@ -19,6 +19,7 @@
 package faas
 
 import (
+	"context"
 	"encoding/json"
 	"scheduler/log"
 )
@@ -65,14 +66,15 @@ func GenFunctionDeploy(host string, function Function) (*APIResponse, error) {
 	return res, err
 }
 
-func GenFunctionExecute(host string, functionName string, payload []byte, contentType string) (*APIResponse, error) {
+// GenFunctionExecute executes the function, the call is aborted when the context is done
+func GenFunctionExecute(ctx context.Context, host string, functionName string, payload []byte, contentType string) (*APIResponse, error) {
 	var res *APIResponse
 	var err error
 
 	if payload == nil {
-		res, err = functionExecuteApiCall(host, functionName)
+		res, err = functionExecuteApiCall(ctx, host, functionName)
 	} else {
-		res, err = functionExecutePostApiCall(host, functionName, payload, contentType)
+		res, err = functionExecutePostApiCall(ctx, host, functionName, payload, contentType)
 	}
 
 	if err != nil {
@@ -23,6 +23,8 @@ import "net/http"
 type Service struct {
 	OpenFaaSFunction Function `json:"openfaas_service,omitempty" bson:"openfaas_service"`
 	Deadline         uint64   `json:"deadline,omitempty" bson:"deadline"`
+	Priority         string   `json:"priority,omitempty" bson:"priority"`
+	QueueWaitMax     uint64   `json:"queue_wait_max,omitempty" bson:"queue_wait_max"`
 }
 
 type CurrentLoad struct {
@@ -23,12 +23,19 @@ import (
 	"scheduler/config"
 	"scheduler/log"
 	"scheduler/metrics"
+	"scheduler/types"
 	"sync"
+	"time"
 )
 
 type Function struct {
-	Name             string
-	RunningInstances uint
+	Name              string
+	RunningInstances  uint
+	Deadline          time.Duration // the deadline of the function set at deploy, 0 if none
+	Priority          int           // the priority of the function set at deploy, the normal one if none
+	QueueWaitMax      time.Duration // the maximum time a job waits in the queue set at deploy, 0 if none
+	MeanExecutionTime float64       // moving average of the execution time in seconds
+	Executions        uint64        // number of executions that contributed to MeanExecutionTime
 }
 
 type ErrorFunctionNotFound struct{}
@@ -41,12 +48,18 @@ func (ErrorFunctionNotFound) Error() string {
  * Code
  */
 
+// executionTimeSmoothing is the weight of the last sample in the execution time moving averages
+const executionTimeSmoothing = 0.2
+
 var functions []*Function
 var totalRunningFunctions uint = 0
 var requestNumber uint64 = 0
+var meanExecutionTime float64 = 0.0
+var executions uint64 = 0
 
 var mutexRunningFunctions sync.Mutex
 var mutexRequestNumber sync.Mutex
+var mutexFunctionsStats sync.Mutex
 
 func GetRunningInstances(functionName string) (uint, error) {
 	mutexRunningFunctions.Lock()
@@ -105,6 +118,126 @@ func GetFreeSlots() int {
 	return int(config.Configuration.GetRunningFunctionMax()) - int(totalRunningFunctions)
 }
 
+// SetFunctionDeadline sets the deadline within which the function is expected to complete, 0 removes it
+func SetFunctionDeadline(functionName string, deadline time.Duration) {
+	mutexFunctionsStats.Lock()
+	defer mutexFunctionsStats.Unlock()
+
+	log.Log.Debugf("Setting %s deadline to %s", functionName, deadline)
+
+	mutexRunningFunctions.Lock()
+	fn := getFunction(functionName, true)
+	mutexRunningFunctions.Unlock()
+	fn.Deadline = deadline
+}
+
+// GetFunctionDeadline returns the deadline of the function, 0 if it has none
+func GetFunctionDeadline(functionName string) time.Duration {
+	mutexFunctionsStats.Lock()
+	defer mutexFunctionsStats.Unlock()
+
+	mutexRunningFunctions.Lock()
+	fn := getFunction(functionName, false)
+	mutexRunningFunctions.Unlock()
+	if fn == nil {
+		return 0
+	}
+	return fn.Deadline
+}
+
+// SetFunctionPriority sets the priority of the jobs of the function which do not specify one
+func SetFunctionPriority(functionName string, priority int) {
+	mutexFunctionsStats.Lock()
+	defer mutexFunctionsStats.Unlock()
+
+	log.Log.Debugf("Setting %s priority to %d", functionName, priority)
+
+	mutexRunningFunctions.Lock()
+	fn := getFunction(functionName, true)
+	mutexRunningFunctions.Unlock()
+	fn.Priority = priority
+}
+
+// GetFunctionPriority returns the priority of the function, the normal one if it has not been set
+func GetFunctionPriority(functionName string) int {
+	mutexFunctionsStats.Lock()
+	defer mutexFunctionsStats.Unlock()
+
+	mutexRunningFunctions.Lock()
+	fn := getFunction(functionName, false)
+	mutexRunningFunctions.Unlock()
+	if fn == nil {
+		return types.PriorityNormal
+	}
+	return fn.Priority
+}
+
+// SetFunctionQueueWaitMax sets the maximum time a job of the function can wait in the queue, 0 removes it
+func SetFunctionQueueWaitMax(functionName string, wait time.Duration) {
+	mutexFunctionsStats.Lock()
+	defer mutexFunctionsStats.Unlock()
+
+	log.Log.Debugf("Setting %s queue wait max to %s", functionName, wait)
+
+	mutexRunningFunctions.Lock()
+	fn := getFunction(functionName, true)
+	mutexRunningFunctions.Unlock()
+	fn.QueueWaitMax = wait
+}
+
+// GetFunctionQueueWaitMax returns the maximum time a job of the function can wait in the queue, 0 if it has none
+func GetFunctionQueueWaitMax(functionName string) time.Duration {
+	mutexFunctionsStats.Lock()
+	defer mutexFunctionsStats.Unlock()
+
+	mutexRunningFunctions.Lock()
+	fn := getFunction(functionName, false)
+	mutexRunningFunctions.Unlock()
+	if fn == nil {
+		return 0
+	}
+	return fn.QueueWaitMax
+}
+
+// PostFunctionExecutionTime updates the moving averages of the execution time of the function and of all functions
+func PostFunctionExecutionTime(functionName string, seconds float64) {
+	mutexFunctionsStats.Lock()
+	defer mutexFunctionsStats.Unlock()
+
+	mutexRunningFunctions.Lock()
+	fn := getFunction(functionName, true)
+	mutexRunningFunctions.Unlock()
+
+	fn.MeanExecutionTime = movingAverage(fn.MeanExecutionTime, fn.Executions, seconds)
+	fn.Executions++
+	meanExecutionTime = movingAverage(meanExecutionTime, executions, seconds)
+	executions++
+}
+
+// GetFunctionMeanExecutionTime returns the average execution time in seconds of the function, false if it has never
+// been executed
+func GetFunctionMeanExecutionTime(functionName string) (float64, bool) {
+	mutexFunctionsStats.Lock()
+	defer mutexFunctionsStats.Unlock()
+
+	mutexRunningFunctions.Lock()
+	fn := getFunction(functionName, false)
+	mutexRunningFunctions.Unlock()
+	if fn == nil || fn.Executions == 0 {
+		return 0.0, false
+	}
+	return fn.MeanExecutionTime, true
+}
+
+// GetMeanExecutionTime returns the average execution time in seconds of all the functions, false if none has been
+// executed yet
+func GetMeanExecutionTime() (float64, bool) {
+	mutexFunctionsStats.Lock()
+	defer mutexFunctionsStats.Unlock()
+
+	return meanExecutionTime, executions > 0
+}
+
 // GetNextRequestNumber returns the next id for the request
 func GetNextRequestNumber() uint64 {
 	mutexRequestNumber.Lock()
@@ -138,3 +271,10 @@ func getFunction(functionName string, createIfNotExists bool) *Function {
 
 	return nil
 }
+
+func movingAverage(mean float64, samples uint64, value float64) float64 {
+	if samples == 0 {
+		return value
+	}
+	return (1-executionTimeSmoothing)*mean + executionTimeSmoothing*value
+}
@@ -54,6 +54,51 @@ var (
 		Help: "Total time for the job for being executed by openfaas",
 	}, []string{"function_name"})
 
+	jobForwardedByGroupCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_job_forwarded_by_group_count",
+		Help: "Number of jobs forwarded by the hierarchical scheduler, inside the local group or to another group",
+	}, []string{"function_name", "tier"})
+
+	jobHedgedCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_job_hedged_count",
+		Help: "Number of jobs sent also to a peer by hedging, by the copy whose response is returned",
+	}, []string{"function_name", "winner"})
+
+	jobDuplicateExecutionsCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_job_duplicate_executions_count",
+		Help: "Number of hedged copies executed whose response has been discarded",
+	}, []string{"function_name"})
+
+	jobQueueExpiredCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_job_queue_expired_count",
+		Help: "Number of jobs removed from the queue since they waited longer than the queue wait max",
+	}, []string{"function_name"})
+
+	jobCancelledCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_job_cancelled_count",
+		Help: "Number of jobs abandoned since their client is gone, by the stage in which they were: queue, execution or forward",
+	}, []string{"function_name", "stage"})
+
+	jobEnqueuedByPriorityCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_job_enqueued_by_priority_count",
+		Help: "Number of jobs enqueued for being executed locally, by priority",
+	}, []string{"function_name", "priority"})
+
+	jobQueueTimeByPriority = promauto.NewSummaryVec(prometheus.SummaryOpts{
+		Name: "scheduler_job_queue_time_by_priority",
+		Help: "Total time for the job to stay in the queue before being executed, by priority",
+	}, []string{"priority"})
+
+	jobScheduledByPriorityCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_job_scheduled_by_priority_count",
+		Help: "Number of jobs scheduled, by priority and by the decision taken: local, forward or drop",
+	}, []string{"function_name", "priority", "decision"})
+
+	adaptiveThreshold = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "scheduler_adaptive_threshold",
+		Help: "The threshold T currently used by the adaptive PowerOfN scheduler, by who uses it: global, shadow or function:<name>",
+	}, []string{"owner"})
+
 	invocationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "scheduler_total_invocations",
 		Help: "The total number of requests for executing a function",
@@ -20,6 +20,13 @@ package metrics
 
 import "strconv"
 
+// Stages in which a job is abandoned since its client is gone
+const (
+	CancelledInQueue     = "queue"
+	CancelledInExecution = "execution"
+	CancelledInForward   = "forward"
+)
+
 func PostJobMetrics(fnName string, code int, hops int, queueTime float64, execTime float64, faasExecutionTime float64) {
 	if enableMetrics {
 		jobHops.WithLabelValues(fnName, strconv.Itoa(code)).Observe(float64(hops))
@@ -70,6 +77,66 @@ func PostJobIsForwarded(fnName string) {
 	}
 }
 
+func PostJobIsForwardedByGroup(fnName string, tier string) {
+	if enableMetrics {
+		jobForwardedByGroupCount.WithLabelValues(fnName, tier).Inc()
+	}
+}
+
+func PostJobIsHedged(fnName string, winner string) {
+	if enableMetrics {
+		jobHedgedCount.WithLabelValues(fnName, winner).Inc()
+	}
+}
+
+func PostJobDuplicateExecution(fnName string) {
+	if enableMetrics {
+		jobDuplicateExecutionsCount.WithLabelValues(fnName).Inc()
+	}
+}
+
+func PostJobQueueExpired(fnName string) {
+	if enableMetrics {
+		jobQueueExpiredCount.WithLabelValues(fnName).Inc()
+	}
+}
+
+func PostJobCancelled(fnName string, stage string) {
+	if enableMetrics {
+		jobCancelledCount.WithLabelValues(fnName, stage).Inc()
+	}
+}
+
+func PostJobEnqueuedWithPriority(fnName string, priority string) {
+	if enableMetrics {
+		jobEnqueuedByPriorityCount.WithLabelValues(fnName, priority).Inc()
+	}
+}
+
+func PostJobQueueTimeWithPriority(priority string, queueTime float64) {
+	if enableMetrics {
+		jobQueueTimeByPriority.WithLabelValues(priority).Observe(queueTime)
+	}
+}
+
+func PostJobScheduledWithPriority(fnName string, priority string, decision string) {
+	if enableMetrics {
+		jobScheduledByPriorityCount.WithLabelValues(fnName, priority, decision).Inc()
+	}
+}
+
+func PostAdaptiveThreshold(owner string, t uint) {
+	if enableMetrics {
+		adaptiveThreshold.WithLabelValues(owner).Set(float64(t))
+	}
+}
+
+func RemoveAdaptiveThreshold(owner string) {
+	if enableMetrics {
+		adaptiveThreshold.DeleteLabelValues(owner)
+	}
+}
+
 /*
  * Init values set
  */
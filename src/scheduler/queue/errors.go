@@ -18,8 +18,46 @@
 
 package queue
 
+import (
+	"fmt"
+	"time"
+)
+
 type ErrorFull struct {}
 
 func (e ErrorFull) Error() string {
 	return "Queue is full"
-}
\ No newline at end of file
+}
+
+type ErrorSubQueueFull struct {
+	key string
+}
+
+func (e ErrorSubQueueFull) Error() string {
+	if e.key == "" {
+		return "Default sub-queue is full"
+	}
+	return "Sub-queue of " + e.key + " is full"
+}
+
+type ErrorBadConfiguration struct {
+	reason string
+}
+
+func (e ErrorBadConfiguration) Error() string {
+	return "Bad queue configuration: " + e.reason
+}
+
+type ErrorExpired struct {
+	wait time.Duration
+}
+
+func (e ErrorExpired) Error() string {
+	return fmt.Sprintf("Job expired after waiting %s in queue", e.wait)
+}
+
+type ErrorCancelled struct{}
+
+func (e ErrorCancelled) Error() string {
+	return "Job cancelled by the client"
+}
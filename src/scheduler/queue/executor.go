@@ -22,27 +22,43 @@ import (
 	"scheduler/faas"
 	"scheduler/log"
 	"scheduler/memdb"
+	"scheduler/metrics"
 	"time"
 )
 
 // executeNow executes the passed job setting the memdb and unlocking both the job and the consumer semaphores
 func executeNow(job *QueuedJob) {
+	// the client of the job is gone while the job was waiting
+	if job.Request.Context.Err() != nil {
+		log.Log.Debugf("[R#%d] %s cancelled, execution skipped", job.Request.Id, job.Request.ServiceName)
+		metrics.PostJobCancelled(job.Request.ServiceName, metrics.CancelledInExecution)
+		job.Semaphore.Signal()
+		consumersSem.Signal()
+		return
+	}
+
 	log.Log.Debugf("%s starting execution, with payload %t and type %s", job.Request.ServiceName, job.Request.Payload != nil, job.Request.ContentType)
 
 	_ = memdb.SetFunctionRunning(job.Request.ServiceName)
 
 	startExecutionTime := time.Now()
 
-	res, err := faas.FunctionExecute(job.Request.ServiceName, job.Request.Payload, job.Request.ContentType)
+	res, err := faas.FunctionExecute(job.Request.Context, job.Request.ServiceName, job.Request.Payload, job.Request.ContentType)
 	// save the res
 	job.Response = res
-	job.Timings.FaasExecutionTime = faas.GetDurationFromExecuteApiCallResponse(res)
+	if res != nil {
+		job.Timings.FaasExecutionTime = faas.GetDurationFromExecuteApiCallResponse(res)
+	}
 	job.Timings.ExecutionTime = time.Since(startExecutionTime).Seconds()
 
-	if err != nil {
+	if err != nil && job.Request.Context.Err() != nil {
+		log.Log.Debugf("[R#%d] %s cancelled, execution aborted", job.Request.Id, job.Request.ServiceName)
+		metrics.PostJobCancelled(job.Request.ServiceName, metrics.CancelledInExecution)
+	} else if err != nil {
 		log.Log.Errorf("Cannot execute service %s: %s", job.Request.ServiceName, err.Error())
 	} else {
 		log.Log.Debugf("%s function executed", job.Request.ServiceName)
+		memdb.PostFunctionExecutionTime(job.Request.ServiceName, job.Timings.ExecutionTime)
 	}
 
 	_ = memdb.SetFunctionStopped(job.Request.ServiceName)
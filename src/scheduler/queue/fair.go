@@ -0,0 +1,290 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package queue
+
+import (
+	"scheduler/config"
+	"scheduler/log"
+	"scheduler/types"
+	"sort"
+)
+
+// The queue is split in sub-queues, by function or by tenant, which are served by deficit round-robin: in its turn a
+// sub-queue is served as many jobs as its weight, then the turn passes to the next sub-queue with jobs waiting. Within a
+// sub-queue jobs are served by priority and then in order of arrival. All the state is guarded by the queue mutex.
+
+// subQueue holds the jobs waiting of a function or tenant
+type subQueue struct {
+	key      string
+	jobs     []*QueuedJob // sorted by decreasing priority
+	deficit  uint         // jobs that can still be served in the current turn
+	enqueued uint64
+	rejected uint64
+}
+
+var queueConfiguration = types.QueueConfiguration{
+	Fairness:      types.QueueFairnessNone,
+	DefaultWeight: 1,
+}
+
+var subQueues = map[string]*subQueue{}
+var activeSubQueues []*subQueue // the sub-queues with jobs waiting, in round-robin order
+var currentSubQueue = 0         // index in activeSubQueues of the sub-queue which has the turn
+var jobsCount = 0
+
+// GetConfiguration returns the configuration of the sub-queues
+func GetConfiguration() types.QueueConfiguration {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return copyConfiguration(&queueConfiguration)
+}
+
+// SetConfiguration changes how the queue is split in sub-queues and their weights and caps. The jobs already waiting
+// are moved to the new sub-queues, even if they exceed the caps.
+func SetConfiguration(conf types.QueueConfiguration) error {
+	if conf.Fairness == "" {
+		conf.Fairness = types.QueueFairnessNone
+	}
+	if conf.Fairness != types.QueueFairnessNone && conf.Fairness != types.QueueFairnessFunction && conf.Fairness != types.QueueFairnessTenant {
+		return ErrorBadConfiguration{"fairness must be none, function or tenant"}
+	}
+	if conf.DefaultWeight == 0 {
+		return ErrorBadConfiguration{"default weight must be positive"}
+	}
+	for key, weight := range conf.Weights {
+		if weight == 0 {
+			return ErrorBadConfiguration{"weight of " + key + " must be positive"}
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// collect the waiting jobs in the order in which they would be served
+	var jobs []*QueuedJob
+	for i := range activeSubQueues {
+		jobs = append(jobs, activeSubQueues[(currentSubQueue+i)%len(activeSubQueues)].jobs...)
+	}
+
+	// the statistics of the sub-queues are kept only if their keys have the same meaning
+	if conf.Fairness != queueConfiguration.Fairness {
+		subQueues = map[string]*subQueue{}
+	}
+	for _, sq := range subQueues {
+		sq.jobs = nil
+		sq.deficit = 0
+	}
+	activeSubQueues = nil
+	currentSubQueue = 0
+	jobsCount = 0
+
+	queueConfiguration = copyConfiguration(&conf)
+	for _, job := range jobs {
+		pushJob(job, false)
+	}
+
+	log.Log.Infof("Queue fairness set to %s, %d jobs moved to %d sub-queues", conf.Fairness, len(jobs), len(activeSubQueues))
+	return nil
+}
+
+// GetStatus returns the fill of the queue and of its sub-queues
+func GetStatus() *types.QueueStatus {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	status := &types.QueueStatus{
+		Fairness:  queueConfiguration.Fairness,
+		Fill:      jobsCount,
+		LengthMax: config.Configuration.GetQueueLengthMax(),
+		SubQueues: []types.SubQueueStatus{},
+	}
+	for _, sq := range subQueues {
+		status.SubQueues = append(status.SubQueues, types.SubQueueStatus{
+			Key:      sq.key,
+			Weight:   getSubQueueWeight(sq.key),
+			Cap:      getSubQueueCap(sq.key),
+			Fill:     len(sq.jobs),
+			Deficit:  sq.deficit,
+			Enqueued: sq.enqueued,
+			Rejected: sq.rejected,
+		})
+	}
+	sort.Slice(status.SubQueues, func(i, j int) bool { return status.SubQueues[i].Key < status.SubQueues[j].Key })
+
+	return status
+}
+
+/*
+ * Sub-queues
+ */
+
+// getSubQueue returns the sub-queue of the request, creating it if needed
+func getSubQueue(req *types.ServiceRequest) *subQueue {
+	key := ""
+	switch queueConfiguration.Fairness {
+	case types.QueueFairnessFunction:
+		key = req.ServiceName
+	case types.QueueFairnessTenant:
+		key = req.Tenant
+	}
+
+	sq, ok := subQueues[key]
+	if !ok {
+		sq = &subQueue{key: key}
+		subQueues[key] = sq
+	}
+	return sq
+}
+
+func getSubQueueWeight(key string) uint {
+	if weight, ok := queueConfiguration.Weights[key]; ok {
+		return weight
+	}
+	return queueConfiguration.DefaultWeight
+}
+
+func getSubQueueCap(key string) uint {
+	if limit, ok := queueConfiguration.Caps[key]; ok {
+		return limit
+	}
+	return queueConfiguration.DefaultCap
+}
+
+// isSubQueueFull tells if the sub-queue reached its cap
+func isSubQueueFull(sq *subQueue) bool {
+	limit := getSubQueueCap(sq.key)
+	return limit > 0 && len(sq.jobs) >= int(limit)
+}
+
+// pushJob inserts the job in its sub-queue, which is kept sorted by decreasing priority. The job is placed after the jobs
+// with its same priority, or before them if ahead is set.
+func pushJob(job *QueuedJob, ahead bool) {
+	sq := getSubQueue(job.Request)
+
+	priority := job.Request.Priority
+	i := 0
+	for ; i < len(sq.jobs); i++ {
+		queuedPriority := sq.jobs[i].Request.Priority
+		if queuedPriority < priority || (ahead && queuedPriority == priority) {
+			break
+		}
+	}
+	sq.jobs = append(sq.jobs, nil)
+	copy(sq.jobs[i+1:], sq.jobs[i:])
+	sq.jobs[i] = job
+	jobsCount++
+
+	// the sub-queue joins the round
+	if len(sq.jobs) == 1 {
+		activeSubQueues = append(activeSubQueues, sq)
+		if len(activeSubQueues) == 1 {
+			currentSubQueue = 0
+			sq.deficit = getSubQueueWeight(sq.key)
+		}
+	}
+}
+
+// popJob removes the next job to be served by deficit round-robin, the queue must not be empty
+func popJob() *QueuedJob {
+	for {
+		sq := activeSubQueues[currentSubQueue]
+		if sq.deficit > 0 {
+			sq.deficit--
+			return removeJob(currentSubQueue, 0)
+		}
+		// the sub-queue used its turn
+		currentSubQueue = (currentSubQueue + 1) % len(activeSubQueues)
+		next := activeSubQueues[currentSubQueue]
+		next.deficit = getSubQueueWeight(next.key)
+	}
+}
+
+// removeJob removes the i-th job of the active sub-queue at index a, which leaves the round if it remains empty
+func removeJob(a int, i int) *QueuedJob {
+	sq := activeSubQueues[a]
+	job := sq.jobs[i]
+	sq.jobs = append(sq.jobs[:i], sq.jobs[i+1:]...)
+	jobsCount--
+
+	if len(sq.jobs) > 0 {
+		return job
+	}
+
+	sq.jobs = nil
+	sq.deficit = 0
+	activeSubQueues = append(activeSubQueues[:a], activeSubQueues[a+1:]...)
+	if a < currentSubQueue {
+		currentSubQueue--
+	} else if a == currentSubQueue && len(activeSubQueues) > 0 {
+		// the turn passes to the next sub-queue
+		currentSubQueue = currentSubQueue % len(activeSubQueues)
+		next := activeSubQueues[currentSubQueue]
+		next.deficit = getSubQueueWeight(next.key)
+	}
+	if len(activeSubQueues) == 0 {
+		currentSubQueue = 0
+	}
+	return job
+}
+
+// removeQueuedJob removes the job from its sub-queue, it returns false if the job is not waiting in the queue
+func removeQueuedJob(job *QueuedJob) bool {
+	for a, sq := range activeSubQueues {
+		for i, queued := range sq.jobs {
+			if queued == job {
+				removeJob(a, i)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findJobToSteal returns the position of the last job that can be stolen in the fullest sub-queue, so that the noisiest
+// function or tenant is offloaded first. It returns -1 if no job can be stolen.
+func findJobToSteal() (int, int) {
+	bestSubQueue, bestJob := -1, -1
+	for a, sq := range activeSubQueues {
+		if bestSubQueue >= 0 && len(sq.jobs) <= len(activeSubQueues[bestSubQueue].jobs) {
+			continue
+		}
+		// jobs that come from other nodes are never stolen
+		for i := len(sq.jobs) - 1; i >= 0; i-- {
+			if !sq.jobs[i].Request.External {
+				bestSubQueue, bestJob = a, i
+				break
+			}
+		}
+	}
+	return bestSubQueue, bestJob
+}
+
+func copyConfiguration(conf *types.QueueConfiguration) types.QueueConfiguration {
+	copied := *conf
+	copied.Weights = map[string]uint{}
+	for key, weight := range conf.Weights {
+		copied.Weights[key] = weight
+	}
+	copied.Caps = map[string]uint{}
+	for key, limit := range conf.Caps {
+		copied.Caps[key] = limit
+	}
+	return copied
+}
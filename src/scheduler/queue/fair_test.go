@@ -0,0 +1,294 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package queue
+
+import (
+	"reflect"
+	"scheduler/types"
+	"testing"
+)
+
+// testJob describes a job pushed in the queue by the tests, the service name is used as id of the job
+type testJob struct {
+	id       string
+	tenant   string
+	priority int
+	external bool
+}
+
+// resetQueue empties the queue and sets its configuration
+func resetQueue(t *testing.T, conf types.QueueConfiguration) {
+	subQueues = map[string]*subQueue{}
+	activeSubQueues = nil
+	currentSubQueue = 0
+	jobsCount = 0
+	if err := SetConfiguration(conf); err != nil {
+		t.Fatalf("cannot set configuration: %s", err.Error())
+	}
+}
+
+func newTestJob(job testJob) *QueuedJob {
+	return &QueuedJob{Request: &types.ServiceRequest{
+		ServiceName: job.id,
+		Tenant:      job.tenant,
+		Priority:    job.priority,
+		External:    job.external,
+	}}
+}
+
+func pushTestJobs(jobs []testJob) map[string]*QueuedJob {
+	pushed := map[string]*QueuedJob{}
+	for _, job := range jobs {
+		pushed[job.id] = newTestJob(job)
+		pushJob(pushed[job.id], false)
+	}
+	return pushed
+}
+
+// popAll pops all the jobs checking the bookkeeping of the queue, it returns the ids of the jobs in the popped order
+func popAll(t *testing.T) []string {
+	var popped []string
+	for jobsCount > 0 {
+		if len(activeSubQueues) == 0 {
+			t.Fatalf("%d jobs counted but no sub-queue is active", jobsCount)
+		}
+		popped = append(popped, popJob().Request.ServiceName)
+		checkBookkeeping(t)
+	}
+	if len(activeSubQueues) != 0 || currentSubQueue != 0 {
+		t.Fatalf("empty queue with %d active sub-queues and turn at %d", len(activeSubQueues), currentSubQueue)
+	}
+	return popped
+}
+
+// checkBookkeeping checks that the count of jobs and the active sub-queues match the jobs in the sub-queues
+func checkBookkeeping(t *testing.T) {
+	count := 0
+	active := 0
+	for _, sq := range subQueues {
+		count += len(sq.jobs)
+		if len(sq.jobs) > 0 {
+			active++
+		}
+	}
+	if count != jobsCount {
+		t.Fatalf("%d jobs counted but %d are in the sub-queues", jobsCount, count)
+	}
+	if active != len(activeSubQueues) {
+		t.Fatalf("%d sub-queues with jobs but %d are active", active, len(activeSubQueues))
+	}
+	if len(activeSubQueues) > 0 && currentSubQueue >= len(activeSubQueues) {
+		t.Fatalf("turn at %d with %d active sub-queues", currentSubQueue, len(activeSubQueues))
+	}
+}
+
+func TestPopJob(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     types.QueueConfiguration
+		jobs     []testJob
+		expected []string
+	}{
+		{
+			name:     "no fairness is fifo",
+			conf:     types.QueueConfiguration{Fairness: types.QueueFairnessNone, DefaultWeight: 1},
+			jobs:     []testJob{{id: "a1"}, {id: "b1"}, {id: "a2"}},
+			expected: []string{"a1", "b1", "a2"},
+		},
+		{
+			name:     "no fairness by priority",
+			conf:     types.QueueConfiguration{Fairness: types.QueueFairnessNone, DefaultWeight: 1},
+			jobs:     []testJob{{id: "low", priority: -1}, {id: "n1"}, {id: "high", priority: 1}, {id: "n2"}},
+			expected: []string{"high", "n1", "n2", "low"},
+		},
+		{
+			name:     "round-robin by tenant",
+			conf:     types.QueueConfiguration{Fairness: types.QueueFairnessTenant, DefaultWeight: 1},
+			jobs:     []testJob{{id: "a1", tenant: "a"}, {id: "a2", tenant: "a"}, {id: "a3", tenant: "a"}, {id: "b1", tenant: "b"}, {id: "c1", tenant: "c"}, {id: "b2", tenant: "b"}},
+			expected: []string{"a1", "b1", "c1", "a2", "b2", "a3"},
+		},
+		{
+			name:     "weighted round-robin",
+			conf:     types.QueueConfiguration{Fairness: types.QueueFairnessTenant, DefaultWeight: 1, Weights: map[string]uint{"a": 2}},
+			jobs:     []testJob{{id: "a1", tenant: "a"}, {id: "a2", tenant: "a"}, {id: "a3", tenant: "a"}, {id: "a4", tenant: "a"}, {id: "b1", tenant: "b"}, {id: "b2", tenant: "b"}, {id: "b3", tenant: "b"}},
+			expected: []string{"a1", "a2", "b1", "a3", "a4", "b2", "b3"},
+		},
+		{
+			name:     "priority within the sub-queue",
+			conf:     types.QueueConfiguration{Fairness: types.QueueFairnessTenant, DefaultWeight: 1},
+			jobs:     []testJob{{id: "a1", tenant: "a"}, {id: "a2", tenant: "a", priority: 1}, {id: "b1", tenant: "b", priority: -1}, {id: "b2", tenant: "b"}},
+			expected: []string{"a2", "b2", "a1", "b1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resetQueue(t, test.conf)
+			pushTestJobs(test.jobs)
+			checkBookkeeping(t)
+
+			popped := popAll(t)
+			if !reflect.DeepEqual(popped, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, popped)
+			}
+		})
+	}
+}
+
+func TestPushJobAhead(t *testing.T) {
+	resetQueue(t, types.QueueConfiguration{Fairness: types.QueueFairnessNone, DefaultWeight: 1})
+	pushTestJobs([]testJob{{id: "high", priority: 1}, {id: "n1"}, {id: "low", priority: -1}})
+	pushJob(newTestJob(testJob{id: "n2"}), true)
+
+	expected := []string{"high", "n2", "n1", "low"}
+	if popped := popAll(t); !reflect.DeepEqual(popped, expected) {
+		t.Fatalf("expected %v, got %v", expected, popped)
+	}
+}
+
+func TestRemoveQueuedJob(t *testing.T) {
+	tests := []struct {
+		name     string
+		pops     int      // jobs popped before removing
+		removed  []string // jobs removed, in order
+		expected []string // jobs popped after removing
+	}{
+		{
+			name:     "job in the middle of a sub-queue",
+			removed:  []string{"a2"},
+			expected: []string{"a1", "b1", "c1", "a3"},
+		},
+		{
+			name:     "sub-queue before the turn",
+			pops:     2,
+			removed:  []string{"a2", "a3"},
+			expected: []string{"c1"},
+		},
+		{
+			name:     "sub-queue after the turn",
+			pops:     1,
+			removed:  []string{"b1"},
+			expected: []string{"c1", "a2", "a3"},
+		},
+		{
+			name:     "sub-queue which has the turn",
+			removed:  []string{"a1", "a2", "a3"},
+			expected: []string{"b1", "c1"},
+		},
+		{
+			name:     "last sub-queue which has the turn",
+			pops:     2,
+			removed:  []string{"c1"},
+			expected: []string{"a2", "a3"},
+		},
+		{
+			name:    "all jobs",
+			removed: []string{"c1", "a1", "b1", "a3", "a2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resetQueue(t, types.QueueConfiguration{Fairness: types.QueueFairnessTenant, DefaultWeight: 1})
+			pushed := pushTestJobs([]testJob{{id: "a1", tenant: "a"}, {id: "a2", tenant: "a"}, {id: "a3", tenant: "a"}, {id: "b1", tenant: "b"}, {id: "c1", tenant: "c"}})
+
+			for i := 0; i < test.pops; i++ {
+				popJob()
+			}
+			for _, id := range test.removed {
+				if !removeQueuedJob(pushed[id]) {
+					t.Fatalf("job %s not found in the queue", id)
+				}
+				checkBookkeeping(t)
+			}
+			if removeQueuedJob(pushed[test.removed[0]]) {
+				t.Fatalf("job %s removed twice", test.removed[0])
+			}
+
+			popped := popAll(t)
+			if !reflect.DeepEqual(popped, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, popped)
+			}
+		})
+	}
+}
+
+func TestSetConfigurationMovesJobs(t *testing.T) {
+	resetQueue(t, types.QueueConfiguration{Fairness: types.QueueFairnessTenant, DefaultWeight: 1})
+	pushTestJobs([]testJob{{id: "a1", tenant: "a"}, {id: "a2", tenant: "a"}, {id: "b1", tenant: "b"}, {id: "b2", tenant: "b"}})
+	popJob()
+
+	if err := SetConfiguration(types.QueueConfiguration{Fairness: types.QueueFairnessNone, DefaultWeight: 1}); err != nil {
+		t.Fatalf("cannot set configuration: %s", err.Error())
+	}
+	checkBookkeeping(t)
+
+	// the jobs keep the round-robin order, starting from the sub-queue which has the turn
+	expected := []string{"a2", "b1", "b2"}
+	if popped := popAll(t); !reflect.DeepEqual(popped, expected) {
+		t.Fatalf("expected %v, got %v", expected, popped)
+	}
+}
+
+func TestFindJobToSteal(t *testing.T) {
+	tests := []struct {
+		name     string
+		jobs     []testJob
+		expected string // empty if no job can be stolen
+	}{
+		{
+			name:     "last job of the fullest sub-queue",
+			jobs:     []testJob{{id: "a1", tenant: "a"}, {id: "b1", tenant: "b"}, {id: "b2", tenant: "b"}},
+			expected: "b2",
+		},
+		{
+			name:     "jobs from other nodes are skipped",
+			jobs:     []testJob{{id: "a1", tenant: "a"}, {id: "a2", tenant: "a", external: true}},
+			expected: "a1",
+		},
+		{
+			name: "only jobs from other nodes",
+			jobs: []testJob{{id: "a1", tenant: "a", external: true}},
+		},
+		{
+			name: "empty queue",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resetQueue(t, types.QueueConfiguration{Fairness: types.QueueFairnessTenant, DefaultWeight: 1})
+			pushTestJobs(test.jobs)
+
+			a, i := findJobToSteal()
+			if test.expected == "" {
+				if a >= 0 {
+					t.Fatalf("expected no job, got %s", activeSubQueues[a].jobs[i].Request.ServiceName)
+				}
+				return
+			}
+			if a < 0 {
+				t.Fatalf("expected %s, got no job", test.expected)
+			}
+			if stolen := activeSubQueues[a].jobs[i].Request.ServiceName; stolen != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, stolen)
+			}
+		})
+	}
+}
@@ -20,6 +20,7 @@
 package queue
 
 import (
+	"context"
 	"scheduler/config"
 	"scheduler/log"
 	"scheduler/memdb"
@@ -30,13 +31,12 @@ import (
 	"time"
 )
 
-var jobsQueue []*QueuedJob
-
 // implementing N producers fixed N consumers
 
 var mutex sync.Mutex
 
-var jobsSem = make(utils.Semaphore, 0)
+// jobsAvailable is signaled every time a job is added to the queue
+var jobsAvailable = sync.NewCond(&mutex)
 var consumersSem = make(utils.Semaphore, config.Configuration.GetRunningFunctionMax())
 
 func init() {
@@ -45,8 +45,17 @@ func init() {
 	metrics.PostQueueSize(int(config.Configuration.GetQueueLengthMax()))
 }
 
-// EnqueueJob enqueues the passed job in the queue and it blocks the caller until the job has been executed
-func EnqueueJob(request *types.ServiceRequest) (*QueuedJob, error) {
+// EnqueueJob enqueues the passed job in the queue and it blocks the caller until the job has been executed. If the job
+// waits in the queue longer than the queue wait max of its function, or of the configuration, it is removed from the
+// queue and ErrorExpired is returned. If the context is done the job is removed from the queue, or its execution is
+// aborted, and ErrorCancelled is returned.
+func EnqueueJob(ctx context.Context, request *types.ServiceRequest) (*QueuedJob, error) {
+	if ctx.Err() != nil {
+		log.Log.Debugf("[R#%d] Job %s cancelled before being enqueued", request.Id, request.ServiceName)
+		metrics.PostJobCancelled(request.ServiceName, metrics.CancelledInQueue)
+		return nil, ErrorCancelled{}
+	}
+
 	mutex.Lock()
 
 	// critical section
@@ -60,47 +69,80 @@ func EnqueueJob(request *types.ServiceRequest) (*QueuedJob, error) {
 			QueueTime:         0.0,
 		},
 	}
-	jobsQueue = append(jobsQueue, job)
-	if len(jobsQueue) == int(config.Configuration.GetQueueLengthMax()) {
+	if jobsCount >= int(config.Configuration.GetQueueLengthMax()) {
 		log.Log.Debugf("[R#%d] Cannot enqueue job %s, queue is full", job.Request.Id, job.Request.ServiceName)
 		mutex.Unlock()
 		return nil, ErrorFull{}
 	}
+	sq := getSubQueue(request)
+	if isSubQueueFull(sq) {
+		sq.rejected++
+		log.Log.Debugf("[R#%d] Cannot enqueue job %s, sub-queue %s is full", job.Request.Id, job.Request.ServiceName, sq.key)
+		mutex.Unlock()
+		return nil, ErrorSubQueueFull{sq.key}
+	}
+	pushJob(job, false)
+	sq.enqueued++
 
-	log.Log.Debugf("[R#%d] Enqueued job %s", job.Request.Id, job.Request.ServiceName)
+	log.Log.Debugf("[R#%d] Enqueued job %s with priority %d", job.Request.Id, job.Request.ServiceName, job.Request.Priority)
 
 	// metrics
 	metrics.PostQueueAssignedSlot()
+	metrics.PostJobEnqueuedWithPriority(job.Request.ServiceName, types.GetPriorityClass(job.Request.Priority))
 
 	// end critical section
 	mutex.Unlock()
 	// add a job
-	jobsSem.Signal()
+	jobsAvailable.Signal()
 
 	// start time
 	startQueueTime := time.Now()
 
-	// lock until job is completed
-	job.Semaphore.Wait(1)
+	// lock until job is completed, or until it expires or it is cancelled
+	waitCtx := ctx
+	waitMax := getQueueWaitMax(request)
+	if waitMax > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, waitMax)
+		defer cancel()
+	}
+	if !job.Semaphore.WaitContext(waitCtx) {
+		if cancelled := ctx.Err() != nil; removeWaitingJob(job, cancelled) {
+			job.Timings.QueueTime = time.Since(startQueueTime).Seconds()
+			if cancelled {
+				log.Log.Debugf("[R#%d] Job %s cancelled while waiting in queue", job.Request.Id, job.Request.ServiceName)
+				return nil, ErrorCancelled{}
+			}
+			log.Log.Debugf("[R#%d] Job %s expired after waiting %s in queue", job.Request.Id, job.Request.ServiceName, waitMax)
+			return nil, ErrorExpired{waitMax}
+		}
+		// the job left the queue in the meanwhile, it is being executed or handed over and it cannot expire anymore, if
+		// it is cancelled its execution is aborted
+		job.Semaphore.Wait(1)
+	}
 
 	// stop time
 	job.Timings.QueueTime = time.Since(startQueueTime).Seconds()
+	metrics.PostJobQueueTimeWithPriority(types.GetPriorityClass(job.Request.Priority), job.Timings.QueueTime)
+
+	// the execution has been skipped or aborted since the job has been cancelled
+	if job.Response == nil && job.HandedOverTo == "" && ctx.Err() != nil {
+		return nil, ErrorCancelled{}
+	}
 
 	return job, nil
 }
 
+// dequeueJob blocks until a job is waiting and removes the next one to be served, picked by deficit round-robin among
+// the sub-queues
 func dequeueJob() *QueuedJob {
-	jobsSem.Wait(1)
 	mutex.Lock()
-
-	job := jobsQueue[0]
-
-	if len(jobsQueue) == 1 {
-		jobsQueue = []*QueuedJob{}
-	} else {
-		jobsQueue = jobsQueue[1:]
+	for jobsCount == 0 {
+		jobsAvailable.Wait()
 	}
 
+	job := popJob()
+
 	// metrics
 	metrics.PostQueueFreedSlot()
 
@@ -108,12 +150,89 @@ func dequeueJob() *QueuedJob {
 	return job
 }
 
+// StealJobs removes up to n jobs waiting in the queue, for handing them over to an idle node which will execute them.
+// Jobs are stolen from the tail of the fullest sub-queue, so the ones with the lowest priority are handed over first.
+// Jobs that come from other nodes are never stolen, so that they are not moved around more than once. The caller must
+// complete or requeue every stolen job, since the one that enqueued the job is still waiting for it.
+func StealJobs(n int) []*QueuedJob {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var stolen []*QueuedJob
+	// steal from the tail since the head is going to be consumed soon
+	for len(stolen) < n {
+		a, i := findJobToSteal()
+		if a < 0 {
+			break
+		}
+		job := removeJob(a, i)
+		log.Log.Debugf("[R#%d] Job %s stolen from queue", job.Request.Id, job.Request.ServiceName)
+
+		stolen = append(stolen, job)
+
+		// metrics
+		metrics.PostQueueFreedSlot()
+	}
+
+	return stolen
+}
+
+// RequeueJob puts back a stolen job that could not be handed over, at the head of the jobs with its same priority
+func RequeueJob(job *QueuedJob) {
+	mutex.Lock()
+	pushJob(job, true)
+	// metrics
+	metrics.PostQueueAssignedSlot()
+	mutex.Unlock()
+
+	log.Log.Debugf("[R#%d] Job %s requeued", job.Request.Id, job.Request.ServiceName)
+
+	jobsAvailable.Signal()
+}
+
+// CompleteHandedOverJob unlocks the one that enqueued a stolen job, giving it the response of the node that executed it
+func CompleteHandedOverJob(job *QueuedJob, nodeIP string, response *types.APIResponse) {
+	job.HandedOverTo = nodeIP
+	job.HandedOverResponse = response
+	job.Semaphore.Signal()
+}
+
 /*
  * Utils
  */
 
+// removeWaitingJob removes the job that expired or has been cancelled from the queue if it is still waiting, it returns
+// false if it has already left the queue
+func removeWaitingJob(job *QueuedJob, cancelled bool) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if !removeQueuedJob(job) {
+		return false
+	}
+
+	// metrics
+	metrics.PostQueueFreedSlot()
+	if cancelled {
+		metrics.PostJobCancelled(job.Request.ServiceName, metrics.CancelledInQueue)
+	} else {
+		metrics.PostJobQueueExpired(job.Request.ServiceName)
+	}
+
+	return true
+}
+
+// getQueueWaitMax returns how long the job can wait in the queue, the one of the function overrides the one of the
+// configuration. It returns 0 if the job can wait indefinitely.
+func getQueueWaitMax(request *types.ServiceRequest) time.Duration {
+	if wait := memdb.GetFunctionQueueWaitMax(request.ServiceName); wait > 0 {
+		return wait
+	}
+	return time.Duration(config.Configuration.GetQueueWaitMax()) * time.Millisecond
+}
+
 func GetQueueFill() int {
-	return len(jobsQueue)
+	return jobsCount
 }
 
 /*
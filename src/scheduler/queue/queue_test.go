@@ -0,0 +1,212 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package queue
+
+import (
+	"context"
+	"scheduler/config"
+	"scheduler/faas"
+	"scheduler/memdb"
+	"scheduler/types"
+	"testing"
+	"time"
+)
+
+// testQueueWaitMax is the queue wait max of the functions whose jobs expire in the tests
+const testQueueWaitMax = 20 * time.Millisecond
+
+// testTimeout is how long the tests wait for a job that is expected to leave the queue
+const testTimeout = 5 * time.Second
+
+type enqueueResult struct {
+	job *QueuedJob
+	err error
+}
+
+// enqueueTestJob enqueues a job of the function in background, the result is sent on the returned channel
+func enqueueTestJob(ctx context.Context, function string) chan enqueueResult {
+	results := make(chan enqueueResult, 1)
+	go func() {
+		job, err := EnqueueJob(ctx, &types.ServiceRequest{ServiceName: function})
+		results <- enqueueResult{job, err}
+	}()
+	return results
+}
+
+// waitJobsCount waits until the passed number of jobs are waiting in the queue
+func waitJobsCount(n int) {
+	for {
+		mutex.Lock()
+		count := jobsCount
+		mutex.Unlock()
+		if count == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func waitEnqueueResult(t *testing.T, results chan enqueueResult) enqueueResult {
+	select {
+	case result := <-results:
+		return result
+	case <-time.After(testTimeout):
+		t.Fatalf("job still waiting after %s", testTimeout)
+	}
+	return enqueueResult{}
+}
+
+func TestEnqueueJobRejected(t *testing.T) {
+	tests := []struct {
+		name      string
+		lengthMax uint
+		conf      types.QueueConfiguration
+		expected  error
+	}{
+		{
+			name:      "queue full",
+			lengthMax: 1,
+			conf:      types.QueueConfiguration{Fairness: types.QueueFairnessNone, DefaultWeight: 1},
+			expected:  ErrorFull{},
+		},
+		{
+			name:      "sub-queue full",
+			lengthMax: 10,
+			conf:      types.QueueConfiguration{Fairness: types.QueueFairnessFunction, DefaultWeight: 1, Caps: map[string]uint{"fn": 1}},
+			expected:  ErrorSubQueueFull{"fn"},
+		},
+	}
+
+	lengthMax := config.Configuration.GetQueueLengthMax()
+	defer config.Configuration.SetQueueLengthMax(lengthMax)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resetQueue(t, test.conf)
+			config.Configuration.SetQueueLengthMax(test.lengthMax)
+			pushTestJobs([]testJob{{id: "fn"}})
+
+			job, err := EnqueueJob(context.Background(), &types.ServiceRequest{ServiceName: "fn"})
+			if err != test.expected {
+				t.Fatalf("expected %v, got job %v and error %v", test.expected, job, err)
+			}
+			if jobsCount != 1 {
+				t.Fatalf("expected the queue to be left with 1 job, got %d", jobsCount)
+			}
+		})
+	}
+}
+
+func TestEnqueueJobExpired(t *testing.T) {
+	resetQueue(t, types.QueueConfiguration{Fairness: types.QueueFairnessNone, DefaultWeight: 1})
+	memdb.SetFunctionQueueWaitMax("expiring", testQueueWaitMax)
+
+	result := waitEnqueueResult(t, enqueueTestJob(context.Background(), "expiring"))
+	if _, expired := result.err.(ErrorExpired); !expired {
+		t.Fatalf("expected the job to expire, got job %v and error %v", result.job, result.err)
+	}
+	checkBookkeeping(t)
+	if jobsCount != 0 {
+		t.Fatalf("expired job left in the queue")
+	}
+}
+
+// TestEnqueueJobDequeuedBeforeExpiring checks that a job which left the queue cannot expire anymore, even if its
+// execution lasts longer than the queue wait max
+func TestEnqueueJobDequeuedBeforeExpiring(t *testing.T) {
+	resetQueue(t, types.QueueConfiguration{Fairness: types.QueueFairnessNone, DefaultWeight: 1})
+	memdb.SetFunctionQueueWaitMax("expiring", testQueueWaitMax)
+
+	results := enqueueTestJob(context.Background(), "expiring")
+	job := dequeueJob()
+	time.Sleep(2 * testQueueWaitMax)
+
+	select {
+	case result := <-results:
+		t.Fatalf("job left the queue before being completed, got job %v and error %v", result.job, result.err)
+	default:
+	}
+
+	job.Response = &faas.APIResponse{StatusCode: 200}
+	job.Semaphore.Signal()
+
+	result := waitEnqueueResult(t, results)
+	if result.err != nil || result.job != job {
+		t.Fatalf("expected the executed job, got job %v and error %v", result.job, result.err)
+	}
+}
+
+func TestEnqueueJobCancelled(t *testing.T) {
+	tests := []struct {
+		name     string
+		dequeued bool // the job is dequeued before being cancelled, so its execution is aborted
+		executed bool // the job is executed anyway after being cancelled
+		expected error
+	}{
+		{name: "waiting in the queue", expected: ErrorCancelled{}},
+		{name: "execution aborted", dequeued: true, expected: ErrorCancelled{}},
+		{name: "executed anyway", dequeued: true, executed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resetQueue(t, types.QueueConfiguration{Fairness: types.QueueFairnessNone, DefaultWeight: 1})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			results := enqueueTestJob(ctx, "fn")
+			var job *QueuedJob
+			if test.dequeued {
+				job = dequeueJob()
+			} else {
+				waitJobsCount(1)
+			}
+			cancel()
+			if job != nil {
+				if test.executed {
+					job.Response = &faas.APIResponse{StatusCode: 200}
+				}
+				job.Semaphore.Signal()
+			}
+
+			result := waitEnqueueResult(t, results)
+			if result.err != test.expected {
+				t.Fatalf("expected error %v, got job %v and error %v", test.expected, result.job, result.err)
+			}
+			checkBookkeeping(t)
+			if jobsCount != 0 {
+				t.Fatalf("cancelled job left in the queue")
+			}
+		})
+	}
+}
+
+func TestEnqueueJobCancelledBeforeEnqueue(t *testing.T) {
+	resetQueue(t, types.QueueConfiguration{Fairness: types.QueueFairnessNone, DefaultWeight: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	job, err := EnqueueJob(ctx, &types.ServiceRequest{ServiceName: "fn"})
+	if err != (ErrorCancelled{}) {
+		t.Fatalf("expected the job to be cancelled, got job %v and error %v", job, err)
+	}
+	if jobsCount != 0 {
+		t.Fatalf("cancelled job enqueued")
+	}
+}
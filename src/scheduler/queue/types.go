@@ -29,6 +29,10 @@ type QueuedJob struct {
 	Semaphore *utils.Semaphore
 	Response  *faas.APIResponse
 	Timings   *Timings
+	// HandedOverTo is the ip of the node that stole the job from the queue and executed it, empty if executed here
+	HandedOverTo string
+	// HandedOverResponse is the response of the node that executed the job, it is set only if HandedOverTo is set
+	HandedOverResponse *types.APIResponse
 }
 
 type Timings struct {
@@ -28,8 +28,8 @@ func main() {
 
 	// init modules
 	config.Start()
-	scheduler.Start()
 	discovery.Start()
+	scheduler.Start()
 	metrics.Start()
 
 	go worker()
@@ -63,16 +63,32 @@ func server() {
 	// new APIs
 	router.HandleFunc("/monitoring/load", api_monitoring.LoadGetLoad).Methods("GET")
 	router.HandleFunc("/monitoring/scale-delay/{function}", api_monitoring.ScaleDelay).Methods("GET")
+	router.HandleFunc("/monitoring/shadow", api.GetShadowDecisions).Methods("GET")
+	router.HandleFunc("/monitoring/decisions", api.GetDecisions).Methods("GET")
+	router.HandleFunc("/monitoring/queue", api_monitoring.QueueStatus).Methods("GET")
 	router.HandleFunc("/peer/function/{function}", api_peer.FunctionExecute).Methods("POST")
+	router.HandleFunc("/peer/steal", api_peer.StealJobs).Methods("POST")
 	// prometheus
 	router.Handle("/metrics", promhttp.Handler())
 	// dev apis
 	router.HandleFunc("/configuration", api.GetConfiguration).Methods("GET")
 	router.HandleFunc("/configuration/scheduler", api.GetScheduler).Methods("GET")
+	router.HandleFunc("/configuration/schedulers", api.GetSchedulers).Methods("GET")
+	router.HandleFunc("/configuration/scheduler/swap", api.GetSchedulerSwap).Methods("GET")
+	router.HandleFunc("/configuration/scheduler/{function}", api.GetFunctionScheduler).Methods("GET")
+	router.HandleFunc("/configuration/learning", api.GetLearningTable).Methods("GET")
+	router.HandleFunc("/configuration/shadow", api.GetShadowScheduler).Methods("GET")
+	router.HandleFunc("/configuration/queue", api.GetQueueConfiguration).Methods("GET")
 	// TODO add auth check on configuration APIs
 	// if config.Configuration.GetRunningEnvironment() == config.RunningEnvironmentDevelopment {
 	router.HandleFunc("/configuration", api.SetConfiguration).Methods("POST")
 	router.HandleFunc("/configuration/scheduler", api.SetScheduler).Methods("POST")
+	router.HandleFunc("/configuration/scheduler/{function}", api.SetFunctionScheduler).Methods("POST")
+	router.HandleFunc("/configuration/scheduler/{function}", api.DeleteFunctionScheduler).Methods("DELETE")
+	router.HandleFunc("/configuration/learning", api.SetLearningTable).Methods("POST")
+	router.HandleFunc("/configuration/shadow", api.SetShadowScheduler).Methods("POST")
+	router.HandleFunc("/configuration/shadow", api.DeleteShadowScheduler).Methods("DELETE")
+	router.HandleFunc("/configuration/queue", api.SetQueueConfiguration).Methods("POST")
 	// }
 
 	server := &http.Server{
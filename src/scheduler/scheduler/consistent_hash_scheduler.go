@@ -0,0 +1,208 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"fmt"
+	"scheduler/config"
+	"scheduler/discovery"
+	"scheduler/log"
+	"scheduler/memdb"
+	"scheduler/scheduler_service"
+	"scheduler/types"
+	"scheduler/utils"
+	"time"
+)
+
+const ConsistentHashSchedulerName = "ConsistentHashScheduler"
+
+func init() {
+	Register(ConsistentHashSchedulerName, "Execute the job on the home node of its function and key in a consistent-hash ring of the nodes, and probe F random nodes only when the home is overloaded", []types.SchedulerParameterInfo{
+		{Name: "F", Type: ParameterTypeUint, Default: 1, Description: "fan-out, number of nodes to probe when the home is overloaded"},
+		{Name: "T", Type: ParameterTypeUint, Default: 0, Description: "threshold, load from which the home is overloaded, 0 for its maximum running functions"},
+		{Name: "Replicas", Type: ParameterTypeUint, Default: 100, Description: "points of every node in the ring"},
+		{Name: "Loss", Type: ParameterTypeBool, Default: true, Description: "discard job if queue is full"},
+		{Name: "MaxHops", Type: ParameterTypeUint, Default: 1, Description: "maximum number of hops"},
+		loadComparisonParameterInfo,
+		samplingParameterInfo,
+		maxPingParameterInfo,
+		failurePolicyParameterInfo,
+		maxRetriesParameterInfo,
+	}, newConsistentHashScheduler)
+}
+
+type ConsistentHashScheduler struct {
+	F              uint                           // fan-out
+	T              uint                           // threshold
+	Replicas       uint                           // points of every node in the ring
+	Loss           bool                           // discard job if queue is full
+	MaxHops        uint                           // maximum number of hops
+	ProbeOptions   scheduler_service.ProbeOptions // how machines are sampled and their loads compared
+	FailureOptions FailureOptions                 // what to do when the peer cannot execute the job
+	ring           *hashRing
+}
+
+func newConsistentHashScheduler(parameters Parameters) (Scheduler, error) {
+	options, err := getProbeOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	failure, err := getFailureOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	if parameters.GetUint("Replicas") == 0 {
+		return nil, BadSchedulerParameters{parameter: "Replicas", reason: "must be greater than 0"}
+	}
+	return &ConsistentHashScheduler{
+		F:              parameters.GetUint("F"),
+		T:              parameters.GetUint("T"),
+		Replicas:       parameters.GetUint("Replicas"),
+		Loss:           parameters.GetBool("Loss"),
+		MaxHops:        parameters.GetUint("MaxHops"),
+		ProbeOptions:   options,
+		FailureOptions: failure,
+		ring:           newHashRing(parameters.GetUint("Replicas")),
+	}, nil
+}
+
+func (s ConsistentHashScheduler) GetFullName() string {
+	return fmt.Sprintf("%s(%d, %d, %d, %t, %d%s%s)", ConsistentHashSchedulerName, s.F, s.T, s.Replicas, s.Loss, s.MaxHops, getProbeOptionsFullName(s.ProbeOptions), getFailureOptionsFullName(s.FailureOptions))
+}
+
+func (s ConsistentHashScheduler) GetScheduler() *types.SchedulerDescriptor {
+	return &types.SchedulerDescriptor{
+		Name: ConsistentHashSchedulerName,
+		Parameters: map[string]interface{}{
+			"F":              s.F,
+			"T":              s.T,
+			"Replicas":       s.Replicas,
+			"Loss":           s.Loss,
+			"MaxHops":        s.MaxHops,
+			"LoadComparison": s.ProbeOptions.LoadComparison,
+			"Sampling":       s.ProbeOptions.Sampling,
+			"MaxPing":        s.ProbeOptions.MaxPing.String(),
+			"FailurePolicy":  s.FailureOptions.Policy,
+			"MaxRetries":     s.FailureOptions.MaxRetries,
+		},
+		State: map[string]interface{}{
+			"RingSize": s.ring.size(),
+		},
+	}
+}
+
+// Schedule a service request. This call is blocking until the job has been executed locally or externally.
+func (s ConsistentHashScheduler) Schedule(req *types.ServiceRequest) (*JobResult, error) {
+	log.Log.Debugf("[R#%d] Scheduling job %s", req.Id, req.ServiceName)
+	currentLoad := memdb.GetTotalRunningFunctions()
+	startedScheduling := time.Now()
+	timingsStart := types.TimingsStart{ArrivedAt: &startedScheduling}
+
+	jobMustExecutedHere := req.External && req.ExternalJobRequest.Hops >= int(s.MaxHops)
+	req.Trace.AddCheck("jobMustExecutedHere", jobMustExecutedHere, "maxHops=%d", s.MaxHops)
+	if jobMustExecutedHere {
+		return executeJobLocally(req, &timingsStart)
+	}
+
+	members, err := discovery.GetListOfMachines()
+	if err != nil {
+		log.Log.Debugf("[R#%d] Cannot get machines for the ring: %s", req.Id, err.Error())
+	}
+	self := discovery.Configuration.MachineIp
+	members = append(members, self)
+
+	// the home is the first owner of the key that the job has not visited, this node included
+	visited := getVisitedMachines(req)
+	homes := s.ring.lookup(members, getAffinityKey(req), s.FailureOptions.maxAttempts(), utils.RemoveStringFromArray(self, visited))
+	if len(homes) == 0 {
+		return s.spillOver(req, currentLoad, &timingsStart)
+	}
+
+	if homes[0] == self {
+		if !isSaturated(scheduler_service.MachineLoad{Running: currentLoad, Max: config.Configuration.GetRunningFunctionMax()}, s.T) {
+			log.Log.Debugf("[R#%d] This node is the home of %s", req.Id, req.ServiceName)
+			req.Trace.SetReason("this node is the home of the job")
+			return executeJobLocally(req, &timingsStart)
+		}
+		return s.spillOver(req, currentLoad, &timingsStart)
+	}
+
+	// save time
+	startedProbingTime := time.Now()
+	timingsStart.StartedProbingAt = &startedProbingTime
+	homeLoad, err := scheduler_service.GetMachineLoad(homes[0], withTrace(s.ProbeOptions, req))
+	// save time
+	endProbingTime := time.Now()
+	timingsStart.EndedProbingAt = &endProbingTime
+
+	if err != nil || isSaturated(*homeLoad, s.T) {
+		log.Log.Debugf("[R#%d] Home %s of %s is overloaded or cannot be probed", req.Id, homes[0], req.ServiceName)
+		req.Trace.SetReason("home %s is overloaded or cannot be probed", homes[0])
+		result, err := s.spillOver(req, currentLoad, &timingsStart)
+		// the home has been probed too, unless its load is the last known one
+		if result != nil && !s.ProbeOptions.CachedLoads {
+			result.ProbingMessages += 1
+		}
+		return result, err
+	}
+
+	// the next owners in the ring are the candidates for retries, this node excluded
+	candidates := utils.RemoveStringFromArray(self, homes)
+	result, err := executeJobExternallyWithFallback(req, candidates, &timingsStart, s.FailureOptions)
+	if result != nil && !s.ProbeOptions.CachedLoads {
+		result.ProbingMessages = 1
+	}
+	return result, err
+}
+
+// spillOver schedules the job as the power-of-d, when its home cannot take it
+func (s ConsistentHashScheduler) spillOver(req *types.ServiceRequest, currentLoad uint, timingsStart *types.TimingsStart) (*JobResult, error) {
+	// save time
+	startedProbingTime := time.Now()
+	if timingsStart.StartedProbingAt == nil {
+		timingsStart.StartedProbingAt = &startedProbingTime
+	}
+	// get N Random machines and ask them for load and rank the ones less loaded than us
+	candidates, probingMessages, _, err := scheduler_service.GetLessLoadedMachinesOfNRandom(s.F, currentLoad, !s.Loss, withTrace(s.ProbeOptions, req), getVisitedMachines(req))
+	// save time
+	endProbingTime := time.Now()
+	timingsStart.EndedProbingAt = &endProbingTime
+
+	var result *JobResult
+	if err != nil {
+		log.Log.Debugf("[R#%d] Error in retrieving machines %s", req.Id, err.Error())
+		req.Trace.SetReason("no peer can take the job: %s", err.Error())
+		result, err = executeJobLocally(req, timingsStart)
+	} else {
+		result, err = executeJobExternallyWithFallback(req, candidates, timingsStart, s.FailureOptions)
+	}
+
+	if result != nil {
+		result.ProbingMessages = probingMessages
+	}
+	return result, err
+}
+
+// getAffinityKey returns the key placed in the ring for the job, which is its function plus its key if it has one
+func getAffinityKey(req *types.ServiceRequest) string {
+	if req.Key == "" {
+		return req.ServiceName
+	}
+	return req.ServiceName + "/" + req.Key
+}
@@ -0,0 +1,162 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"fmt"
+	"scheduler/log"
+	"scheduler/memdb"
+	"scheduler/scheduler_service"
+	"scheduler/types"
+	"time"
+)
+
+const DeadlineSchedulerName = "DeadlineScheduler"
+
+func init() {
+	Register(DeadlineSchedulerName, "Execute locally if the job deadline can be met, otherwise forward to the least loaded of F random nodes or reject", []types.SchedulerParameterInfo{
+		{Name: "F", Type: ParameterTypeUint, Default: 1, Description: "fan-out, number of nodes to probe"},
+		{Name: "Reject", Type: ParameterTypeBool, Default: true, Description: "reject jobs that would miss the deadline and cannot be forwarded"},
+		{Name: "Loss", Type: ParameterTypeBool, Default: true, Description: "discard job if queue is full"},
+		{Name: "MaxHops", Type: ParameterTypeUint, Default: 1, Description: "maximum number of hops"},
+		loadComparisonParameterInfo,
+		samplingParameterInfo,
+		maxPingParameterInfo,
+		failurePolicyParameterInfo,
+		maxRetriesParameterInfo,
+	}, newDeadlineScheduler)
+}
+
+// DeadlineScheduler uses the deadline of the job, which is set by the client or by the function, and the estimated
+// local completion time to decide whether to keep the job. Jobs without a deadline are always executed locally.
+type DeadlineScheduler struct {
+	F              uint                           // fan-out
+	Reject         bool                           // reject jobs that would miss the deadline and cannot be forwarded
+	Loss           bool                           // discard job if queue is full
+	MaxHops        uint                           // maximum number of hops
+	ProbeOptions   scheduler_service.ProbeOptions // how machines are sampled and their loads compared
+	FailureOptions FailureOptions                 // what to do when the peer cannot execute the job
+}
+
+func newDeadlineScheduler(parameters Parameters) (Scheduler, error) {
+	options, err := getProbeOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	failure, err := getFailureOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	return &DeadlineScheduler{
+		F:              parameters.GetUint("F"),
+		Reject:         parameters.GetBool("Reject"),
+		Loss:           parameters.GetBool("Loss"),
+		MaxHops:        parameters.GetUint("MaxHops"),
+		ProbeOptions:   options,
+		FailureOptions: failure,
+	}, nil
+}
+
+func (s DeadlineScheduler) GetFullName() string {
+	return fmt.Sprintf("%s(%d, %t, %t, %d%s%s)", DeadlineSchedulerName, s.F, s.Reject, s.Loss, s.MaxHops, getProbeOptionsFullName(s.ProbeOptions), getFailureOptionsFullName(s.FailureOptions))
+}
+
+func (s DeadlineScheduler) GetScheduler() *types.SchedulerDescriptor {
+	return &types.SchedulerDescriptor{
+		Name: DeadlineSchedulerName,
+		Parameters: map[string]interface{}{
+			"F":              s.F,
+			"Reject":         s.Reject,
+			"Loss":           s.Loss,
+			"MaxHops":        s.MaxHops,
+			"LoadComparison": s.ProbeOptions.LoadComparison,
+			"Sampling":       s.ProbeOptions.Sampling,
+			"MaxPing":        s.ProbeOptions.MaxPing.String(),
+			"FailurePolicy":  s.FailureOptions.Policy,
+			"MaxRetries":     s.FailureOptions.MaxRetries,
+		},
+	}
+}
+
+// Schedule a service request. This call is blocking until the job has been executed locally or externally.
+func (s DeadlineScheduler) Schedule(req *types.ServiceRequest) (*JobResult, error) {
+	log.Log.Debugf("[R#%d] Scheduling job %s", req.Id, req.ServiceName)
+	currentLoad := memdb.GetTotalRunningFunctions()
+	startedScheduling := time.Now()
+	timingsStart := types.TimingsStart{ArrivedAt: &startedScheduling}
+
+	if req.Deadline == nil {
+		return executeJobLocally(req, &timingsStart)
+	}
+
+	left := req.Deadline.Sub(startedScheduling)
+	estimated := estimateLocalCompletionTime(req.ServiceName)
+	deadlineMissed := estimated > left
+	req.Trace.AddCheck("deadlineMissed", deadlineMissed, "estimated=%s left=%s", estimated, left)
+	jobMustExecutedHere := req.External && req.ExternalJobRequest.Hops >= int(s.MaxHops)
+	req.Trace.AddCheck("jobMustExecutedHere", jobMustExecutedHere, "maxHops=%d", s.MaxHops)
+
+	log.Log.Debugf("[R#%d] deadlineMissed %t (estimated=%s left=%s) - jobMustExecutedHere %t", req.Id, deadlineMissed, estimated, left, jobMustExecutedHere)
+
+	if !deadlineMissed {
+		return executeJobLocally(req, &timingsStart)
+	}
+
+	var probingMessages uint
+	if !jobMustExecutedHere {
+		// save time
+		startedProbingTime := time.Now()
+		timingsStart.StartedProbingAt = &startedProbingTime
+		// get N Random machines and ask them for load and rank the ones less loaded than us
+		var candidates []string
+		var err error
+		candidates, probingMessages, _, err = scheduler_service.GetLessLoadedMachinesOfNRandom(s.F, currentLoad, !s.Loss, withTrace(s.ProbeOptions, req), getVisitedMachines(req))
+		// save time
+		endProbingTime := time.Now()
+		timingsStart.EndedProbingAt = &endProbingTime
+
+		if err == nil {
+			result, err := executeJobExternallyWithFallback(req, candidates, &timingsStart, s.FailureOptions)
+			if result != nil {
+				result.ProbingMessages = probingMessages
+			}
+			return result, err
+		}
+		log.Log.Debugf("[R#%d] Error in retrieving machines: %s", req.Id, err.Error())
+		req.Trace.SetReason("no peer can take the job: %s", err.Error())
+	}
+
+	// nobody can take the job, so we run it anyway or we reject it right now instead of letting it miss the deadline
+	if !s.Reject {
+		return executeJobLocally(req, &timingsStart)
+	}
+
+	log.Log.Debugf("[R#%d] %s rejected, deadline cannot be met", req.Id, req.ServiceName)
+	req.Trace.SetReason("deadline cannot be met")
+	now := time.Now()
+	timingsStart.ScheduledAt = &now
+	result := &JobResult{
+		Response:          nil,
+		Timings:           &types.Timings{},
+		TimingsStart:      &timingsStart,
+		ExternalExecution: false,
+	}
+	result.ProbingMessages = probingMessages
+	return result, JobDeadlineCannotBeMet{estimated: estimated, left: left}
+}
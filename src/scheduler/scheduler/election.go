@@ -0,0 +1,133 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"bytes"
+	"net"
+	"scheduler/discovery"
+	"scheduler/log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// leaderElection elects a leader among this node and the discovered machines, picking the one with the lowest machine id
+// and the lowest ip among the ones with the same id, so that all the nodes agree on the leader without exchanging
+// messages. The discovery reports the machine id of every machine as its name. The leader is kept for the lease time, then it is elected again among the machines still discovered. A leader that
+// cannot be reached is revoked and not elected again for the lease time.
+type leaderElection struct {
+	lease     time.Duration
+	leader    string               // ip of the current leader, empty if not elected yet
+	expiresAt time.Time            // time at which the lease of the leader expires
+	revoked   map[string]time.Time // leaders that cannot be reached and are not elected until the time
+	mutex     sync.Mutex
+}
+
+func newLeaderElection(lease time.Duration) *leaderElection {
+	return &leaderElection{lease: lease, revoked: map[string]time.Time{}}
+}
+
+// getLeader returns the current leader, electing a new one if its lease expired
+func (e *leaderElection) getLeader() (string, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	now := time.Now()
+	if e.leader != "" && now.Before(e.expiresAt) {
+		return e.leader, nil
+	}
+
+	machines, err := discovery.GetMachines()
+	if err != nil {
+		return "", err
+	}
+
+	candidates := append(machines, discovery.Machine{IP: discovery.Configuration.MachineIp, Name: discovery.Configuration.MachineId})
+	previous := e.leader
+	var elected *discovery.Machine
+	for i, machine := range candidates {
+		if machine.IP == "" {
+			continue
+		}
+		if until, ok := e.revoked[machine.IP]; ok {
+			if now.Before(until) {
+				continue
+			}
+			delete(e.revoked, machine.IP)
+		}
+		if elected == nil || compareMachines(machine, *elected) < 0 {
+			elected = &candidates[i]
+		}
+	}
+	e.leader = ""
+	if elected != nil {
+		e.leader = elected.IP
+	}
+	e.expiresAt = now.Add(e.lease)
+
+	if e.leader != previous {
+		log.Log.Infof("Elected leader %s in place of %s", e.leader, previous)
+	}
+	return e.leader, nil
+}
+
+// revoke the passed leader since it cannot be reached, it will not be elected for the lease time
+func (e *leaderElection) revoke(leader string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.leader != leader {
+		return
+	}
+	log.Log.Warningf("Leader %s cannot be reached, revoking it", leader)
+	e.revoked[leader] = time.Now().Add(e.lease)
+	e.leader = ""
+}
+
+// getState returns the current leader and the expiration of its lease, without electing a new one
+func (e *leaderElection) getState() (string, time.Time) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.leader, e.expiresAt
+}
+
+// compareMachines compares two machines by their id, the ones without an id coming last, and then by their ip
+func compareMachines(a discovery.Machine, b discovery.Machine) int {
+	if a.Name != b.Name {
+		if a.Name == "" {
+			return 1
+		}
+		if b.Name == "" {
+			return -1
+		}
+		return strings.Compare(a.Name, b.Name)
+	}
+	return compareIps(a.IP, b.IP)
+}
+
+// compareIps compares two ips by their bytes, falling back on their strings if they cannot be parsed
+func compareIps(a string, b string) int {
+	ipA := net.ParseIP(a)
+	ipB := net.ParseIP(b)
+	if ipA == nil || ipB == nil {
+		return bytes.Compare([]byte(a), []byte(b))
+	}
+	return bytes.Compare(ipA.To16(), ipB.To16())
+}
@@ -18,7 +18,10 @@
 
 package scheduler
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type JobCannotBeScheduled struct {
 	reason string
@@ -28,14 +31,53 @@ func (e JobCannotBeScheduled) Error() string {
 	return fmt.Sprintf("Job cannot be scheduled: %s", e.reason)
 }
 
+type JobDeadlineCannotBeMet struct {
+	estimated time.Duration
+	left      time.Duration
+}
+
+func (e JobDeadlineCannotBeMet) Error() string {
+	return fmt.Sprintf("Job deadline cannot be met: estimated completion in %s but %s left", e.estimated, e.left)
+}
+
 type CannotChangeScheduler struct{}
 
 func (e CannotChangeScheduler) Error() string {
-	return "SchedulerDescriptor cannot be changed right now"
+	return "SchedulerDescriptor cannot be changed right now, functions are running: swap it with mode=drain"
 }
 
-type BadSchedulerParameters struct{}
+type BadSchedulerParameters struct {
+	parameter string
+	reason    string
+}
 
 func (e BadSchedulerParameters) Error() string {
-	return "Bad passed parameters for scheduler"
+	if e.parameter == "" {
+		return fmt.Sprintf("Bad passed parameters for scheduler: %s", e.reason)
+	}
+	return fmt.Sprintf("Bad passed parameter %s for scheduler: %s", e.parameter, e.reason)
+}
+
+type UnknownScheduler struct {
+	name string
+}
+
+func (e UnknownScheduler) Error() string {
+	return fmt.Sprintf("Scheduler %s is not registered", e.name)
+}
+
+type NotLearningScheduler struct {
+	name string
+}
+
+func (e NotLearningScheduler) Error() string {
+	return fmt.Sprintf("Scheduler %s does not learn a table", e.name)
+}
+
+type BadLearningTable struct {
+	reason string
+}
+
+func (e BadLearningTable) Error() string {
+	return fmt.Sprintf("Bad learning table: %s", e.reason)
 }
@@ -0,0 +1,154 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"fmt"
+	"scheduler/log"
+	"scheduler/types"
+)
+
+// Policies applied when a peer cannot execute a forwarded job because it is down or it does not reply in time
+const (
+	FailurePolicyFail       = "fail"        // reply with an error
+	FailurePolicyLocal      = "local"       // execute the job locally
+	FailurePolicyRetry      = "retry"       // forward the job to the next-best candidate
+	FailurePolicyRetryLocal = "retry_local" // forward the job to the next-best candidate and then execute it locally
+)
+
+var FailurePolicies = []string{FailurePolicyFail, FailurePolicyLocal, FailurePolicyRetry, FailurePolicyRetryLocal}
+
+// FailureOptions tell what to do when forwarding a job fails
+type FailureOptions struct {
+	Policy     string // one of FailurePolicies
+	MaxRetries uint   // maximum number of other candidates tried with the retry policies
+}
+
+// Parameters of the schedulers that forward jobs, they are read by getFailureOptionsParameters
+var (
+	failurePolicyParameterInfo = types.SchedulerParameterInfo{
+		Name:        "FailurePolicy",
+		Type:        ParameterTypeString,
+		Default:     FailurePolicyFail,
+		Description: fmt.Sprintf("what to do when the peer cannot execute the job, one of %v", FailurePolicies),
+	}
+	maxRetriesParameterInfo = types.SchedulerParameterInfo{
+		Name:        "MaxRetries",
+		Type:        ParameterTypeUint,
+		Default:     1,
+		Description: "maximum number of other peers tried when the failure policy retries",
+	}
+)
+
+func isValidFailurePolicy(policy string) bool {
+	for _, p := range FailurePolicies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
+func (o FailureOptions) retries() bool {
+	return o.Policy == FailurePolicyRetry || o.Policy == FailurePolicyRetryLocal
+}
+
+func (o FailureOptions) fallsBackLocally() bool {
+	return o.Policy == FailurePolicyLocal || o.Policy == FailurePolicyRetryLocal
+}
+
+// maxAttempts returns how many peers can be tried at most for forwarding a job
+func (o FailureOptions) maxAttempts() int {
+	if o.retries() {
+		return 1 + int(o.MaxRetries)
+	}
+	return 1
+}
+
+// getFailureOptionsParameters returns the validated failure options from the parameters
+func getFailureOptionsParameters(parameters Parameters) (FailureOptions, error) {
+	options := FailureOptions{
+		Policy:     parameters.GetString(failurePolicyParameterInfo.Name),
+		MaxRetries: parameters.GetUint(maxRetriesParameterInfo.Name),
+	}
+	if !isValidFailurePolicy(options.Policy) {
+		return options, BadSchedulerParameters{
+			parameter: failurePolicyParameterInfo.Name,
+			reason:    fmt.Sprintf("must be one of %v", FailurePolicies),
+		}
+	}
+	return options, nil
+}
+
+// getFailureOptionsFullName returns the suffix for the full name of schedulers that forward jobs, it is empty with the
+// default policy
+func getFailureOptionsFullName(options FailureOptions) string {
+	if options.Policy == FailurePolicyFail {
+		return ""
+	}
+	if options.retries() {
+		return fmt.Sprintf(", %s(%d)", options.Policy, options.MaxRetries)
+	}
+	return fmt.Sprintf(", %s", options.Policy)
+}
+
+// executeJobExternallyWithFallback forwards the job to the first candidate and, if it fails, applies the failure
+// policy. Candidates must be ordered from the best to the worst, the failed ones are reported in the result.
+func executeJobExternallyWithFallback(req *types.ServiceRequest, candidates []string, timingsStart *types.TimingsStart, failure FailureOptions) (*JobResult, error) {
+	var failedPeers []string
+	var result *JobResult
+	var err error
+
+	for i := 0; i < len(candidates) && i < failure.maxAttempts(); i++ {
+		result, err = forwardJob(req, candidates[i], timingsStart)
+		if result == nil {
+			return nil, err
+		}
+		if err == nil {
+			result.ForwardFailures = failedPeers
+			return result, nil
+		}
+
+		// the client is gone, there is no one to retry for
+		if req.Context.Err() != nil {
+			return result, err
+		}
+
+		log.Log.Debugf("[R#%d] %s cannot be executed at %s: %s", req.Id, req.ServiceName, candidates[i], err.Error())
+		req.Trace.SetReason("forward to %s failed: %s", candidates[i], err.Error())
+		failedPeers = append(failedPeers, candidates[i])
+	}
+
+	if failure.fallsBackLocally() {
+		log.Log.Debugf("[R#%d] %s falls back to local execution after %d failures", req.Id, req.ServiceName, len(failedPeers))
+		req.Trace.SetReason("executed locally after %d forward failures", len(failedPeers))
+		result, err = executeJobLocally(req, timingsStart)
+		if result != nil {
+			result.ForwardFailures = failedPeers
+			result.LocalFallback = true
+		}
+		return result, err
+	}
+
+	// fail fast, the result carries the error response
+	if result != nil {
+		result.ForwardFailures = failedPeers
+	}
+	return result, nil
+}
@@ -28,20 +28,42 @@ import (
 
 const ForwardSchedulerName = "ForwardScheduler"
 
+func init() {
+	Register(ForwardSchedulerName, "Forward every job to a random node, used for testing purposes", []types.SchedulerParameterInfo{
+		{Name: "MaxHops", Type: ParameterTypeUint, Default: 1, Description: "maximum number of hops"},
+		failurePolicyParameterInfo,
+		maxRetriesParameterInfo,
+	}, newForwardScheduler)
+}
+
 // This scheduler forward all of its jobs to a random node, this is used for testing purposes
 type ForwardScheduler struct {
-	MaxHops uint // maximum number of hops
+	MaxHops        uint           // maximum number of hops
+	FailureOptions FailureOptions // what to do when the peer cannot execute the job
+}
+
+func newForwardScheduler(parameters Parameters) (Scheduler, error) {
+	failure, err := getFailureOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	return &ForwardScheduler{
+		MaxHops:        parameters.GetUint("MaxHops"),
+		FailureOptions: failure,
+	}, nil
 }
 
 func (s ForwardScheduler) GetFullName() string {
-	return fmt.Sprintf("%s(%d)", ForwardSchedulerName, s.MaxHops)
+	return fmt.Sprintf("%s(%d%s)", ForwardSchedulerName, s.MaxHops, getFailureOptionsFullName(s.FailureOptions))
 }
 
 func (s ForwardScheduler) GetScheduler() *types.SchedulerDescriptor {
 	return &types.SchedulerDescriptor{
 		Name: ForwardSchedulerName,
-		Parameters: []string{
-			fmt.Sprintf("%d", s.MaxHops),
+		Parameters: map[string]interface{}{
+			"MaxHops":       s.MaxHops,
+			"FailurePolicy": s.FailureOptions.Policy,
+			"MaxRetries":    s.FailureOptions.MaxRetries,
 		},
 	}
 }
@@ -53,27 +75,29 @@ func (s ForwardScheduler) Schedule(req *types.ServiceRequest) (*JobResult, error
 	timingsStart := types.TimingsStart{ArrivedAt: &now}
 
 	jobMustExecutedHere := req.External && req.ExternalJobRequest.Hops >= int(s.MaxHops)
+	req.Trace.AddCheck("jobMustExecutedHere", jobMustExecutedHere, "maxHops=%d", s.MaxHops)
 
 	// check if the balancing condition is hit
 	if !jobMustExecutedHere {
 		// save time
 		startedProbingTime := time.Now()
 		timingsStart.StartedProbingAt = &startedProbingTime
-		// get N Random machines and ask them for load and pick the least loaded
-		randomMachine, err := discovery.GetNRandomMachines(1)
+		// get a random machine, and the ones to retry with if it fails
+		randomMachines, err := discovery.GetNRandomMachines(uint(s.FailureOptions.maxAttempts()), getVisitedMachines(req))
 		// save time
 		endProbingTime := time.Now()
 		timingsStart.EndedProbingAt = &endProbingTime
 		if err != nil {
 			log.Log.Debugf("Error in retrieving machines %s", err.Error())
+			req.Trace.SetReason("no peer can take the job: %s", err.Error())
 			return executeJobLocally(req, &timingsStart)
 		}
-		if len(randomMachine) == 0 {
+		if len(randomMachines) == 0 {
 			log.Log.Debugf("No random machines retrieved")
 			return executeJobLocally(req, &timingsStart)
 		}
-		log.Log.Debugf("Forwarding to random machine: %s", randomMachine)
-		return executeJobExternally(req, randomMachine[0], &timingsStart)
+		log.Log.Debugf("Forwarding to random machine: %s", randomMachines[0])
+		return executeJobExternallyWithFallback(req, randomMachines, &timingsStart, s.FailureOptions)
 	}
 
 	return executeJobLocally(req, &timingsStart)
@@ -0,0 +1,148 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"scheduler/config"
+	"scheduler/log"
+	"scheduler/types"
+	"sync"
+)
+
+// schedulers of the functions which do not use the current one, indexed by function name
+var functionSchedulers = map[string]*runningScheduler{}
+var functionSchedulersMutex sync.RWMutex
+
+// loadFunctionSchedulers loads the schedulers of the functions from the configuration file, the ones that cannot be
+// created are skipped and their functions use the current scheduler
+func loadFunctionSchedulers() {
+	file, err := ioutil.ReadFile(config.GetConfigFunctionsSchedulerFilePath())
+	if err != nil {
+		log.Log.Debugf("Could not read the functions scheduler configuration file at %s, no function has its scheduler", config.GetConfigFunctionsSchedulerFilePath())
+		return
+	}
+
+	var descriptors map[string]*types.SchedulerDescriptor
+	err = json.Unmarshal(file, &descriptors)
+	if err != nil {
+		log.Log.Warningf("Could not decode functions scheduler config file: %s", err.Error())
+		return
+	}
+
+	for function, descriptor := range descriptors {
+		err = SetFunctionScheduler(function, descriptor)
+		if err != nil {
+			log.Log.Warningf("Could not set scheduler of function %s from config file: %s", function, err.Error())
+			continue
+		}
+		log.Log.Infof("Init function %s with '%s' scheduler", function, GetNameForFunction(function))
+	}
+}
+
+/*
+ * Actions
+ */
+
+// SetFunctionScheduler sets the scheduler used for the jobs of the function in place of the current one. Since every
+// function has its own instance it can be replaced at any time: in-flight jobs complete on the old one.
+func SetFunctionScheduler(function string, sched *types.SchedulerDescriptor) error {
+	newScheduler, err := newSchedulerFromDescriptorWith(sched, Parameters{ownerParameter: getFunctionSchedulerOwner(function)})
+	if err != nil {
+		return err
+	}
+
+	functionSchedulersMutex.Lock()
+	if old, ok := functionSchedulers[function]; ok {
+		old.retire()
+	}
+	functionSchedulers[function] = newRunningScheduler(newScheduler)
+	functionSchedulersMutex.Unlock()
+
+	return nil
+}
+
+// RemoveFunctionScheduler makes the function use the current scheduler again, it returns false if the function had no
+// scheduler of its own
+func RemoveFunctionScheduler(function string) bool {
+	functionSchedulersMutex.Lock()
+	defer functionSchedulersMutex.Unlock()
+
+	old, ok := functionSchedulers[function]
+	if !ok {
+		return false
+	}
+	old.retire()
+	delete(functionSchedulers, function)
+	return true
+}
+
+/*
+ * types.SchedulerDescriptor info related
+ */
+
+// GetFunctionScheduler returns the scheduler of the function, nil if it uses the current one
+func GetFunctionScheduler(function string) *types.SchedulerDescriptor {
+	functionSchedulersMutex.RLock()
+	defer functionSchedulersMutex.RUnlock()
+
+	sched, ok := functionSchedulers[function]
+	if !ok {
+		return nil
+	}
+	return sched.GetScheduler()
+}
+
+// GetFunctionSchedulers returns the schedulers of all the functions that do not use the current one
+func GetFunctionSchedulers() map[string]*types.SchedulerDescriptor {
+	functionSchedulersMutex.RLock()
+	defer functionSchedulersMutex.RUnlock()
+
+	descriptors := make(map[string]*types.SchedulerDescriptor, len(functionSchedulers))
+	for function, sched := range functionSchedulers {
+		descriptors[function] = sched.GetScheduler()
+	}
+	return descriptors
+}
+
+// GetNameForFunction returns the full name of the scheduler that schedules the jobs of the function
+func GetNameForFunction(function string) string {
+	functionSchedulersMutex.RLock()
+	sched, ok := functionSchedulers[function]
+	functionSchedulersMutex.RUnlock()
+
+	if !ok {
+		return GetName()
+	}
+	return sched.GetFullName()
+}
+
+// acquireSchedulerForFunction returns the scheduler of the function, or the current one if it has none, marking a new
+// in-flight call on it, the caller must release it
+func acquireSchedulerForFunction(function string) *runningScheduler {
+	functionSchedulersMutex.RLock()
+	defer functionSchedulersMutex.RUnlock()
+
+	if sched, ok := functionSchedulers[function]; ok {
+		sched.acquire()
+		return sched
+	}
+	return acquireCurrentScheduler()
+}
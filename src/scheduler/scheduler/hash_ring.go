@@ -0,0 +1,134 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// hashRing is a consistent-hash ring of machines, each one placed at many points of the ring, so that when a machine
+// joins or leaves only the keys next to its points move to another machine
+type hashRing struct {
+	replicas uint              // points of each machine in the ring
+	members  []string          // sorted ips of the machines in the ring
+	points   []uint64          // sorted points of the ring
+	owners   map[uint64]string // machine of every point
+	mutex    sync.Mutex
+}
+
+func newHashRing(replicas uint) *hashRing {
+	if replicas == 0 {
+		replicas = 1
+	}
+	return &hashRing{replicas: replicas, owners: map[uint64]string{}}
+}
+
+// lookup returns up to n distinct machines that own the key, walking the ring clockwise from the key and skipping the
+// excluded ones. The ring is rebuilt first if the passed members differ from the ones it was built with.
+func (r *hashRing) lookup(members []string, key string, n int, exclude []string) []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.update(members)
+	if len(r.points) == 0 || n <= 0 {
+		return nil
+	}
+
+	var owners []string
+	seen := map[string]bool{}
+	for _, e := range exclude {
+		seen[e] = true
+	}
+
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hashKey(key) })
+	for i := 0; i < len(r.points) && len(owners) < n; i++ {
+		owner := r.owners[r.points[(start+i)%len(r.points)]]
+		if seen[owner] {
+			continue
+		}
+		seen[owner] = true
+		owners = append(owners, owner)
+	}
+	return owners
+}
+
+func (r *hashRing) size() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.members)
+}
+
+// update rebuilds the ring with the passed members if they changed, the caller must hold the mutex
+func (r *hashRing) update(members []string) {
+	sorted := make([]string, 0, len(members))
+	seen := map[string]bool{}
+	for _, m := range members {
+		if m != "" && !seen[m] {
+			seen[m] = true
+			sorted = append(sorted, m)
+		}
+	}
+	sort.Strings(sorted)
+
+	if len(sorted) == len(r.members) {
+		changed := false
+		for i := range sorted {
+			if sorted[i] != r.members[i] {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			return
+		}
+	}
+
+	r.members = sorted
+	r.points = make([]uint64, 0, len(sorted)*int(r.replicas))
+	r.owners = make(map[uint64]string, len(sorted)*int(r.replicas))
+	for _, member := range sorted {
+		for i := uint(0); i < r.replicas; i++ {
+			point := hashKey(member + "#" + strconv.Itoa(int(i)))
+			// on collision the point stays to the first member, members are sorted so every node agrees
+			if _, ok := r.owners[point]; ok {
+				continue
+			}
+			r.owners[point] = member
+			r.points = append(r.points, point)
+		}
+	}
+	sort.Slice(r.points, func(a, b int) bool { return r.points[a] < r.points[b] })
+}
+
+// hashKey places the key in the ring. The fnv hash is mixed with the finalizer of murmur3, since keys that differ only in
+// their last characters, like the points of a machine, would otherwise be placed next to each other.
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	k := h.Sum64()
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
@@ -0,0 +1,131 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+var testMembers = []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}
+
+func TestHashRingLookup(t *testing.T) {
+	tests := []struct {
+		name     string
+		members  []string
+		n        int
+		exclude  []string
+		expected int // number of owners returned
+	}{
+		{name: "home", members: testMembers, n: 1, expected: 1},
+		{name: "replicas", members: testMembers, n: 3, expected: 3},
+		{name: "more than members", members: testMembers, n: 10, expected: 4},
+		{name: "excluded", members: testMembers, n: 10, exclude: []string{"10.0.0.1", "10.0.0.3"}, expected: 2},
+		{name: "all excluded", members: testMembers, n: 1, exclude: testMembers, expected: 0},
+		{name: "duplicated and empty members", members: []string{"10.0.0.1", "", "10.0.0.1"}, n: 2, expected: 1},
+		{name: "no members", n: 1, expected: 0},
+		{name: "no owners requested", members: testMembers, n: 0, expected: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ring := newHashRing(16)
+			owners := ring.lookup(test.members, "function", test.n, test.exclude)
+			if len(owners) != test.expected {
+				t.Fatalf("expected %d owners, got %v", test.expected, owners)
+			}
+
+			seen := map[string]bool{}
+			for _, owner := range owners {
+				if seen[owner] {
+					t.Fatalf("owner %s returned twice in %v", owner, owners)
+				}
+				seen[owner] = true
+				for _, excluded := range test.exclude {
+					if owner == excluded {
+						t.Fatalf("excluded owner %s returned in %v", owner, owners)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestHashRingAgreement checks that every node finds the same owners, whatever the order in which it knows the members
+func TestHashRingAgreement(t *testing.T) {
+	reversed := make([]string, len(testMembers))
+	for i, member := range testMembers {
+		reversed[len(testMembers)-1-i] = member
+	}
+
+	ring := newHashRing(16)
+	other := newHashRing(16)
+	for i := 0; i < 100; i++ {
+		key := "function-" + strconv.Itoa(i)
+		owners := ring.lookup(testMembers, key, 2, nil)
+		otherOwners := other.lookup(reversed, key, 2, nil)
+		if !reflect.DeepEqual(owners, otherOwners) {
+			t.Fatalf("owners of %s differ: %v and %v", key, owners, otherOwners)
+		}
+	}
+}
+
+// TestHashRingMemberLeaves checks that when a member leaves only its keys move, and that the ring is rebuilt
+func TestHashRingMemberLeaves(t *testing.T) {
+	ring := newHashRing(16)
+	homes := map[string]string{}
+	for i := 0; i < 1000; i++ {
+		key := "function-" + strconv.Itoa(i)
+		homes[key] = ring.lookup(testMembers, key, 1, nil)[0]
+	}
+
+	left := testMembers[0]
+	remaining := testMembers[1:]
+	moved := 0
+	for key, home := range homes {
+		newHome := ring.lookup(remaining, key, 1, nil)[0]
+		if newHome == left {
+			t.Fatalf("key %s still owned by %s which left", key, left)
+		}
+		if home != left && newHome != home {
+			t.Fatalf("key %s moved from %s to %s, but %s did not leave", key, home, newHome, home)
+		}
+		if home == left {
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Fatalf("no key was owned by %s", left)
+	}
+	if ring.size() != len(remaining) {
+		t.Fatalf("expected %d members, got %d", len(remaining), ring.size())
+	}
+
+	// the keys of the member which left are spread on the others
+	owners := map[string]bool{}
+	for key, home := range homes {
+		if home == left {
+			owners[ring.lookup(remaining, key, 1, nil)[0]] = true
+		}
+	}
+	if len(owners) < 2 {
+		t.Fatalf("keys of %s moved all to %v", left, owners)
+	}
+}
@@ -0,0 +1,247 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"fmt"
+	"scheduler/log"
+	"scheduler/memdb"
+	"scheduler/metrics"
+	"scheduler/scheduler_service"
+	"scheduler/types"
+	"time"
+)
+
+const HedgedSchedulerName = "HedgedScheduler"
+
+const (
+	hedgeWinnerLocal    = "local"
+	hedgeWinnerExternal = "external"
+	hedgeWinnerNone     = "none"
+)
+
+func init() {
+	Register(HedgedSchedulerName, "Execute the job locally and send a copy to the least loaded of F random nodes after Delay, the first response wins", []types.SchedulerParameterInfo{
+		{Name: "F", Type: ParameterTypeUint, Default: 1, Description: "fan-out, number of nodes to probe"},
+		{Name: "Delay", Type: ParameterTypeDuration, Default: "0s", Description: "time after which the copy is sent if the job is not completed, 0s for sending it immediately"},
+		{Name: "Loss", Type: ParameterTypeBool, Default: true, Description: "discard job if queue is full"},
+		{Name: "MaxHops", Type: ParameterTypeUint, Default: 1, Description: "maximum number of hops"},
+		loadComparisonParameterInfo,
+		samplingParameterInfo,
+		maxPingParameterInfo,
+	}, newHedgedScheduler)
+}
+
+// HedgedScheduler trades duplicate executions for a lower tail latency. The loser copy is abandoned with the request
+// once the response of the winner is sent, if it completed already its response is ignored and it is counted in metrics
+// as a duplicate execution.
+type HedgedScheduler struct {
+	F            uint                           // fan-out
+	Delay        time.Duration                  // time after which the copy is sent
+	Loss         bool                           // discard job if queue is full
+	MaxHops      uint                           // maximum number of hops
+	ProbeOptions scheduler_service.ProbeOptions // how machines are sampled and their loads compared
+}
+
+// hedgedExecution is the outcome of one of the two copies of a job
+type hedgedExecution struct {
+	result   *JobResult
+	err      error
+	local    bool // the copy has been executed locally
+	launched bool // the copy has been actually sent for execution
+}
+
+func (e hedgedExecution) succeeded() bool {
+	return e.launched && e.err == nil && e.result != nil && e.result.Response != nil && e.result.Response.StatusCode < 500
+}
+
+func newHedgedScheduler(parameters Parameters) (Scheduler, error) {
+	options, err := getProbeOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	return &HedgedScheduler{
+		F:            parameters.GetUint("F"),
+		Delay:        parameters.GetDuration("Delay"),
+		Loss:         parameters.GetBool("Loss"),
+		MaxHops:      parameters.GetUint("MaxHops"),
+		ProbeOptions: options,
+	}, nil
+}
+
+func (s HedgedScheduler) GetFullName() string {
+	return fmt.Sprintf("%s(%d, %dms, %t, %d%s)", HedgedSchedulerName, s.F, s.Delay.Milliseconds(), s.Loss, s.MaxHops, getProbeOptionsFullName(s.ProbeOptions))
+}
+
+func (s HedgedScheduler) GetScheduler() *types.SchedulerDescriptor {
+	return &types.SchedulerDescriptor{
+		Name: HedgedSchedulerName,
+		Parameters: map[string]interface{}{
+			"F":              s.F,
+			"Delay":          fmt.Sprintf("%dms", s.Delay.Milliseconds()),
+			"Loss":           s.Loss,
+			"MaxHops":        s.MaxHops,
+			"LoadComparison": s.ProbeOptions.LoadComparison,
+			"Sampling":       s.ProbeOptions.Sampling,
+			"MaxPing":        s.ProbeOptions.MaxPing.String(),
+		},
+	}
+}
+
+// Schedule a service request. This call is blocking until the job has been executed locally or externally.
+func (s HedgedScheduler) Schedule(req *types.ServiceRequest) (*JobResult, error) {
+	log.Log.Debugf("[R#%d] Scheduling job %s", req.Id, req.ServiceName)
+	currentLoad := memdb.GetTotalRunningFunctions()
+	startedScheduling := time.Now()
+	timingsStart := types.TimingsStart{ArrivedAt: &startedScheduling}
+
+	jobMustExecutedHere := req.External && req.ExternalJobRequest.Hops >= int(s.MaxHops)
+	req.Trace.AddCheck("jobMustExecutedHere", jobMustExecutedHere, "maxHops=%d", s.MaxHops)
+	if jobMustExecutedHere {
+		return executeJobLocally(req, &timingsStart)
+	}
+
+	// save time
+	startedProbingTime := time.Now()
+	timingsStart.StartedProbingAt = &startedProbingTime
+	// get N Random machines and ask them for load and pick the least loaded
+	leastLoaded, probingMessages, _, err := scheduler_service.GetLeastLoadedMachineOfNRandom(s.F, currentLoad, !s.Loss, withTrace(s.ProbeOptions, req), getVisitedMachines(req))
+	// save time
+	endProbingTime := time.Now()
+	timingsStart.EndedProbingAt = &endProbingTime
+
+	if err != nil {
+		log.Log.Debugf("[R#%d] No peer for the hedged copy: %s", req.Id, err.Error())
+		req.Trace.SetReason("no peer for the hedged copy: %s", err.Error())
+		result, err := executeJobLocally(req, &timingsStart)
+		if result != nil {
+			result.ProbingMessages = probingMessages
+		}
+		return result, err
+	}
+
+	results := make(chan hedgedExecution, 2)
+	localFailed := make(chan struct{})
+	decided := make(chan struct{})
+
+	// the copies have their own request and timings since executing locally changes the payload of the request
+	localReq := *req
+	localTimingsStart := timingsStart
+	go func() {
+		result, err := executeJobLocally(&localReq, &localTimingsStart)
+		execution := hedgedExecution{result: result, err: err, local: true, launched: true}
+		if !execution.succeeded() {
+			close(localFailed)
+		}
+		results <- execution
+	}()
+
+	externalTimingsStart := timingsStart
+	go func() {
+		results <- s.executeCopyExternally(req, leastLoaded, &externalTimingsStart, localFailed, decided)
+	}()
+
+	// the first copy that succeeds wins
+	var winner *hedgedExecution
+	var failed []hedgedExecution
+	for len(failed) < 2 {
+		execution := <-results
+		if execution.succeeded() {
+			winner = &execution
+			break
+		}
+		failed = append(failed, execution)
+	}
+	close(decided)
+
+	// the loser is still running, wait for it in background for counting it
+	if winner != nil && len(failed) == 0 {
+		go s.collectLoser(req, results)
+	}
+
+	// if both copies failed we reply with the external one if it has been sent, since the local one failed first
+	if winner == nil {
+		for i := range failed {
+			if failed[i].local {
+				winner = &failed[i]
+			}
+		}
+		for i := range failed {
+			if !failed[i].local && failed[i].launched {
+				winner = &failed[i]
+			}
+		}
+	}
+
+	hedged := !winner.local
+	for _, execution := range failed {
+		hedged = hedged || (!execution.local && execution.launched)
+	}
+
+	log.Log.Debugf("[R#%d] hedged=%t winner local=%t succeeded=%t", req.Id, hedged, winner.local, winner.succeeded())
+
+	if hedged {
+		if !winner.succeeded() {
+			metrics.PostJobIsHedged(req.ServiceName, hedgeWinnerNone)
+		} else if winner.local {
+			metrics.PostJobIsHedged(req.ServiceName, hedgeWinnerLocal)
+		} else {
+			metrics.PostJobIsHedged(req.ServiceName, hedgeWinnerExternal)
+		}
+	}
+
+	if winner.result != nil {
+		winner.result.Hedged = hedged
+		winner.result.ProbingMessages = probingMessages
+	}
+	return winner.result, winner.err
+}
+
+// executeCopyExternally sends the copy of the job to the peer when Delay expires, or before if the local execution
+// fails. The copy is not sent if the job has been already completed.
+func (s HedgedScheduler) executeCopyExternally(req *types.ServiceRequest, peer string, timingsStart *types.TimingsStart, localFailed chan struct{}, decided chan struct{}) hedgedExecution {
+	// the delay is counted from the job arrival, so probing is part of it
+	delay := s.Delay - time.Since(*timingsStart.ArrivedAt)
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-localFailed:
+		case <-decided:
+			return hedgedExecution{}
+		}
+	}
+	select {
+	case <-decided:
+		return hedgedExecution{}
+	default:
+	}
+
+	log.Log.Debugf("[R#%d] Sending hedged copy of %s to %s", req.Id, req.ServiceName, peer)
+	result, err := executeJobExternally(req, peer, timingsStart)
+	return hedgedExecution{result: result, err: err, launched: true}
+}
+
+// collectLoser waits for the copy which lost and counts it as duplicate if it has been executed
+func (s HedgedScheduler) collectLoser(req *types.ServiceRequest, results chan hedgedExecution) {
+	execution := <-results
+	if execution.succeeded() {
+		log.Log.Debugf("[R#%d] Hedged copy of %s executed twice, local=%t discarded", req.Id, req.ServiceName, execution.local)
+		metrics.PostJobDuplicateExecution(req.ServiceName)
+	}
+}
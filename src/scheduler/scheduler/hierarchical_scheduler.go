@@ -0,0 +1,215 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"fmt"
+	"scheduler/discovery"
+	"scheduler/log"
+	"scheduler/memdb"
+	"scheduler/metrics"
+	"scheduler/scheduler_service"
+	"scheduler/types"
+	"sync/atomic"
+	"time"
+)
+
+const HierarchicalSchedulerName = "HierarchicalScheduler"
+
+// Tiers in which the hierarchical scheduler forwards jobs
+const (
+	hierarchicalTierGroup      = "group"
+	hierarchicalTierCrossGroup = "cross_group"
+)
+
+func init() {
+	Register(HierarchicalSchedulerName, "Balance the job inside the local group when the load is above T, and forward it to other groups only when the whole local group is above T", []types.SchedulerParameterInfo{
+		{Name: "F", Type: ParameterTypeUint, Default: 0, Description: "fan-out inside the local group, 0 for probing all of its nodes, which are all probed anyway before forwarding to the other groups"},
+		{Name: "CrossF", Type: ParameterTypeUint, Default: 1, Description: "fan-out to the other groups"},
+		{Name: "T", Type: ParameterTypeUint, Default: 2, Description: "threshold, load from which a node needs balancing"},
+		{Name: "Group", Type: ParameterTypeString, Default: "", Description: "local group, empty for the fog network of the node"},
+		{Name: "Loss", Type: ParameterTypeBool, Default: true, Description: "discard job if queue is full"},
+		{Name: "MaxHops", Type: ParameterTypeUint, Default: 1, Description: "maximum number of hops"},
+		loadComparisonParameterInfo,
+		samplingParameterInfo,
+		maxPingParameterInfo,
+		failurePolicyParameterInfo,
+		maxRetriesParameterInfo,
+	}, newHierarchicalScheduler)
+}
+
+type HierarchicalScheduler struct {
+	F              uint                           // fan-out inside the local group
+	CrossF         uint                           // fan-out to the other groups
+	T              uint                           // threshold
+	Group          string                         // local group
+	Loss           bool                           // discard job if queue is full
+	MaxHops        uint                           // maximum number of hops
+	ProbeOptions   scheduler_service.ProbeOptions // how machines are sampled and their loads compared
+	FailureOptions FailureOptions                 // what to do when the peer cannot execute the job
+	forwards       *hierarchicalForwards
+}
+
+// hierarchicalForwards counts the jobs forwarded in every tier
+type hierarchicalForwards struct {
+	group      uint64
+	crossGroup uint64
+}
+
+func newHierarchicalScheduler(parameters Parameters) (Scheduler, error) {
+	options, err := getProbeOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	failure, err := getFailureOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	return &HierarchicalScheduler{
+		F:              parameters.GetUint("F"),
+		CrossF:         parameters.GetUint("CrossF"),
+		T:              parameters.GetUint("T"),
+		Group:          parameters.GetString("Group"),
+		Loss:           parameters.GetBool("Loss"),
+		MaxHops:        parameters.GetUint("MaxHops"),
+		ProbeOptions:   options,
+		FailureOptions: failure,
+		forwards:       &hierarchicalForwards{},
+	}, nil
+}
+
+func (s HierarchicalScheduler) GetFullName() string {
+	return fmt.Sprintf("%s(%d, %d, %d, %s, %t, %d%s%s)", HierarchicalSchedulerName, s.F, s.CrossF, s.T, s.getGroup(), s.Loss, s.MaxHops, getProbeOptionsFullName(s.ProbeOptions), getFailureOptionsFullName(s.FailureOptions))
+}
+
+func (s HierarchicalScheduler) GetScheduler() *types.SchedulerDescriptor {
+	return &types.SchedulerDescriptor{
+		Name: HierarchicalSchedulerName,
+		Parameters: map[string]interface{}{
+			"F":              s.F,
+			"CrossF":         s.CrossF,
+			"T":              s.T,
+			"Group":          s.Group,
+			"Loss":           s.Loss,
+			"MaxHops":        s.MaxHops,
+			"LoadComparison": s.ProbeOptions.LoadComparison,
+			"Sampling":       s.ProbeOptions.Sampling,
+			"MaxPing":        s.ProbeOptions.MaxPing.String(),
+			"FailurePolicy":  s.FailureOptions.Policy,
+			"MaxRetries":     s.FailureOptions.MaxRetries,
+		},
+		State: map[string]interface{}{
+			"LocalGroup":         s.getGroup(),
+			"GroupForwards":      atomic.LoadUint64(&s.forwards.group),
+			"CrossGroupForwards": atomic.LoadUint64(&s.forwards.crossGroup),
+		},
+	}
+}
+
+// Schedule a service request. This call is blocking until the job has been executed locally or externally.
+func (s HierarchicalScheduler) Schedule(req *types.ServiceRequest) (*JobResult, error) {
+	log.Log.Debugf("[R#%d] Scheduling job %s", req.Id, req.ServiceName)
+	currentLoad := memdb.GetTotalRunningFunctions()
+	startedScheduling := time.Now()
+	timingsStart := types.TimingsStart{ArrivedAt: &startedScheduling}
+
+	balancingHit := currentLoad >= s.T
+	req.Trace.AddCheck("balancingHit", balancingHit, "load=%d threshold=%d", currentLoad, s.T)
+	jobMustExecutedHere := req.External && req.ExternalJobRequest.Hops >= int(s.MaxHops)
+	req.Trace.AddCheck("jobMustExecutedHere", jobMustExecutedHere, "maxHops=%d", s.MaxHops)
+
+	log.Log.Debugf("[R#%d] balancingHit %t - jobMustExecutedHere %t", req.Id, balancingHit, jobMustExecutedHere)
+
+	if !balancingHit || jobMustExecutedHere {
+		return executeJobLocally(req, &timingsStart)
+	}
+
+	inGroup, others, err := discovery.GetMachinesByGroup(s.getGroup())
+	if err != nil {
+		log.Log.Debugf("[R#%d] Cannot get machines by group: %s", req.Id, err.Error())
+		return executeJobLocally(req, &timingsStart)
+	}
+	visited := getVisitedMachines(req)
+
+	// save time
+	startedProbingTime := time.Now()
+	timingsStart.StartedProbingAt = &startedProbingTime
+
+	// first tier, the machines of the group below the threshold, as if they had a load of T
+	var probingMessages uint
+	var candidates []string
+	if s.F > 0 {
+		candidates, probingMessages, _, err = scheduler_service.GetLessLoadedMachinesOfNRandom(s.F, s.T, !s.Loss, withTrace(s.ProbeOptions, req), append(visited, others...))
+	}
+	// a sample cannot tell that the whole group is above the threshold, so in that case all of its machines are probed
+	if s.F == 0 || err != nil {
+		var groupProbingMessages uint
+		candidates, groupProbingMessages, _, err = scheduler_service.GetLessLoadedMachinesOfAll(s.T, !s.Loss, withTrace(s.ProbeOptions, req), append(visited, others...))
+		probingMessages += groupProbingMessages
+	}
+	tier := hierarchicalTierGroup
+
+	// second tier, the whole group is above the threshold so the machines of the other groups less loaded than us
+	if _, groupAboveThreshold := err.(scheduler_service.NoLessLoadedMachine); groupAboveThreshold {
+		log.Log.Debugf("[R#%d] No machine of group %s below threshold: %s", req.Id, s.getGroup(), err.Error())
+		req.Trace.SetReason("no machine of group %s below threshold", s.getGroup())
+		var crossProbingMessages uint
+		candidates, crossProbingMessages, _, err = scheduler_service.GetLessLoadedMachinesOfNRandom(s.CrossF, currentLoad, !s.Loss, withTrace(s.ProbeOptions, req), append(visited, inGroup...))
+		probingMessages += crossProbingMessages
+		tier = hierarchicalTierCrossGroup
+	}
+
+	// save time
+	endProbingTime := time.Now()
+	timingsStart.EndedProbingAt = &endProbingTime
+
+	var result *JobResult
+	if err != nil {
+		log.Log.Debugf("[R#%d] Error in retrieving machines %s", req.Id, err.Error())
+		req.Trace.SetReason("no peer can take the job: %s", err.Error())
+		result, err = executeJobLocally(req, &timingsStart)
+	} else {
+		result, err = executeJobExternallyWithFallback(req, candidates, &timingsStart, s.FailureOptions)
+		if result != nil && result.ExternalExecution {
+			s.countForward(req.ServiceName, tier)
+		}
+	}
+
+	if result != nil {
+		result.ProbingMessages = probingMessages
+	}
+	return result, err
+}
+
+// getGroup returns the local group, that is the fog network of the node if it is not set
+func (s HierarchicalScheduler) getGroup() string {
+	if s.Group == "" {
+		return discovery.Configuration.MachineFogNetId
+	}
+	return s.Group
+}
+
+func (s HierarchicalScheduler) countForward(function string, tier string) {
+	if tier == hierarchicalTierGroup {
+		atomic.AddUint64(&s.forwards.group, 1)
+	} else {
+		atomic.AddUint64(&s.forwards.crossGroup, 1)
+	}
+	metrics.PostJobIsForwardedByGroup(function, tier)
+}
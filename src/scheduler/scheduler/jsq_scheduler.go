@@ -0,0 +1,126 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"fmt"
+	"scheduler/log"
+	"scheduler/memdb"
+	"scheduler/scheduler_service"
+	"scheduler/types"
+	"time"
+)
+
+const JoinShortestQueueSchedulerName = "JoinShortestQueueScheduler"
+
+func init() {
+	Register(JoinShortestQueueSchedulerName, "Probe all the known nodes and forward to the least loaded if its load is below ours", []types.SchedulerParameterInfo{
+		{Name: "Loss", Type: ParameterTypeBool, Default: true, Description: "discard job if queue is full"},
+		{Name: "MaxHops", Type: ParameterTypeUint, Default: 1, Description: "maximum number of hops"},
+		loadComparisonParameterInfo,
+		maxPingParameterInfo,
+		failurePolicyParameterInfo,
+		maxRetriesParameterInfo,
+	}, newJoinShortestQueueScheduler)
+}
+
+// JoinShortestQueueScheduler is the full-probe baseline of PowerOfNScheduler: every job probes all the machines
+type JoinShortestQueueScheduler struct {
+	Loss           bool                           // discard job if queue is full
+	MaxHops        uint                           // maximum number of hops
+	ProbeOptions   scheduler_service.ProbeOptions // how machines are sampled and their loads compared
+	FailureOptions FailureOptions                 // what to do when the peer cannot execute the job
+}
+
+func newJoinShortestQueueScheduler(parameters Parameters) (Scheduler, error) {
+	options, err := getProbeOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	failure, err := getFailureOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	return &JoinShortestQueueScheduler{
+		Loss:           parameters.GetBool("Loss"),
+		MaxHops:        parameters.GetUint("MaxHops"),
+		ProbeOptions:   options,
+		FailureOptions: failure,
+	}, nil
+}
+
+func (s JoinShortestQueueScheduler) GetFullName() string {
+	return fmt.Sprintf("%s(%t, %d%s%s)", JoinShortestQueueSchedulerName, s.Loss, s.MaxHops, getProbeOptionsFullName(s.ProbeOptions), getFailureOptionsFullName(s.FailureOptions))
+}
+
+func (s JoinShortestQueueScheduler) GetScheduler() *types.SchedulerDescriptor {
+	return &types.SchedulerDescriptor{
+		Name: JoinShortestQueueSchedulerName,
+		Parameters: map[string]interface{}{
+			"Loss":           s.Loss,
+			"MaxHops":        s.MaxHops,
+			"LoadComparison": s.ProbeOptions.LoadComparison,
+			"MaxPing":        s.ProbeOptions.MaxPing.String(),
+			"FailurePolicy":  s.FailureOptions.Policy,
+			"MaxRetries":     s.FailureOptions.MaxRetries,
+		},
+	}
+}
+
+// Schedule a service request. This call is blocking until the job has been executed locally or externally.
+func (s JoinShortestQueueScheduler) Schedule(req *types.ServiceRequest) (*JobResult, error) {
+	log.Log.Debugf("[R#%d] Scheduling job %s", req.Id, req.ServiceName)
+	currentLoad := memdb.GetTotalRunningFunctions()
+	startedScheduling := time.Now()
+	timingsStart := types.TimingsStart{ArrivedAt: &startedScheduling}
+
+	jobMustExecutedHere := req.External && req.ExternalJobRequest.Hops >= int(s.MaxHops)
+	req.Trace.AddCheck("jobMustExecutedHere", jobMustExecutedHere, "maxHops=%d", s.MaxHops)
+
+	log.Log.Debugf("[R#%d] jobMustExecutedHere %t", req.Id, jobMustExecutedHere)
+
+	if jobMustExecutedHere {
+		return executeJobLocally(req, &timingsStart)
+	}
+
+	// save time
+	startedProbingTime := time.Now()
+	timingsStart.StartedProbingAt = &startedProbingTime
+	// ask all machines for load and rank the ones less loaded than us
+	candidates, probingMessages, _, err := scheduler_service.GetLessLoadedMachinesOfAll(currentLoad, !s.Loss, withTrace(s.ProbeOptions, req), getVisitedMachines(req))
+	// save time
+	endProbingTime := time.Now()
+	timingsStart.EndedProbingAt = &endProbingTime
+
+	var result *JobResult
+	if err != nil {
+		log.Log.Debugf("[R#%d] Error in retrieving machines: %s", req.Id, err.Error())
+		req.Trace.SetReason("no peer can take the job: %s", err.Error())
+		// no machine less loaded than us, we are obliged to run the job in this machine or discard the job
+		// if we cannot handle it
+		result, err = executeJobLocally(req, &timingsStart)
+	} else {
+		result, err = executeJobExternallyWithFallback(req, candidates, &timingsStart, s.FailureOptions)
+	}
+
+	if result != nil {
+		result.ProbingMessages = probingMessages
+	}
+	return result, err
+}
@@ -0,0 +1,410 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"fmt"
+	"scheduler/discovery"
+	"scheduler/log"
+	"scheduler/memdb"
+	"scheduler/scheduler_service"
+	"scheduler/types"
+	"scheduler/utils"
+	"sort"
+	"sync"
+	"time"
+)
+
+const LearningSchedulerName = "LearningScheduler"
+
+// Algorithms used for learning the policy
+const (
+	LearningAlgorithmQLearning = "qlearning" // the value of an action includes the discounted value of the next state
+	LearningAlgorithmBandit    = "bandit"    // the value of an action is only its average reward
+)
+
+var LearningAlgorithms = []string{LearningAlgorithmQLearning, LearningAlgorithmBandit}
+
+// Actions that the learning scheduler can take for a job
+const (
+	LearningActionLocal         = "local"          // execute the job locally
+	LearningActionForwardRandom = "forward_random" // forward the job to a random node
+	LearningActionForwardProbed = "forward_probed" // forward the job to the least loaded of F random nodes
+)
+
+var LearningActions = []string{LearningActionLocal, LearningActionForwardRandom, LearningActionForwardProbed}
+
+// learningStates are the ways of computing the state in which a job is scheduled, given the local load
+var learningStates = map[string]func(req *types.ServiceRequest, load uint) string{
+	"load": func(req *types.ServiceRequest, load uint) string {
+		return fmt.Sprintf("load=%d", load)
+	},
+	"function": func(req *types.ServiceRequest, load uint) string {
+		return fmt.Sprintf("function=%s", req.ServiceName)
+	},
+	"function_load": func(req *types.ServiceRequest, load uint) string {
+		return fmt.Sprintf("function=%s,load=%d", req.ServiceName, load)
+	},
+}
+
+// learningRewards are the ways of computing the reward of a scheduled job, given its total time in seconds
+var learningRewards = map[string]func(dropped bool, totalTime float64, dropPenalty float64) float64{
+	"time": func(dropped bool, totalTime float64, dropPenalty float64) float64 {
+		if dropped {
+			return -dropPenalty
+		}
+		return -totalTime
+	},
+	"drops": func(dropped bool, totalTime float64, dropPenalty float64) float64 {
+		if dropped {
+			return -1
+		}
+		return 0
+	},
+}
+
+func init() {
+	Register(LearningSchedulerName, "Learn online which action is best for a job among executing it locally, forwarding it to a random node or to a probed node", []types.SchedulerParameterInfo{
+		{Name: "Algorithm", Type: ParameterTypeString, Default: LearningAlgorithmQLearning, Description: fmt.Sprintf("learning algorithm, one of %v", LearningAlgorithms)},
+		{Name: "State", Type: ParameterTypeString, Default: "function_load", Description: fmt.Sprintf("state of a job, one of %v", getLearningNames(learningStates))},
+		{Name: "Reward", Type: ParameterTypeString, Default: "time", Description: fmt.Sprintf("reward of a job, one of %v", getLearningNames(learningRewards))},
+		{Name: "Alpha", Type: ParameterTypeFloat, Default: 0.1, Description: "learning rate"},
+		{Name: "Gamma", Type: ParameterTypeFloat, Default: 0.9, Description: "discount of the next state value, not used by the bandit"},
+		{Name: "Epsilon", Type: ParameterTypeFloat, Default: 0.1, Description: "probability of taking a random action"},
+		{Name: "DropPenalty", Type: ParameterTypeFloat, Default: 10.0, Description: "penalty of a dropped job, in seconds for the time reward"},
+		{Name: "F", Type: ParameterTypeUint, Default: 1, Description: "fan-out, number of nodes to probe for the forward_probed action"},
+		{Name: "MaxHops", Type: ParameterTypeUint, Default: 1, Description: "maximum number of hops"},
+		loadComparisonParameterInfo,
+		samplingParameterInfo,
+		maxPingParameterInfo,
+	}, newLearningScheduler)
+}
+
+type LearningScheduler struct {
+	Algorithm    string                         // learning algorithm
+	State        string                         // state of a job
+	Reward       string                         // reward of a job
+	Alpha        float64                        // learning rate
+	Gamma        float64                        // discount of the next state value
+	Epsilon      float64                        // probability of taking a random action
+	DropPenalty  float64                        // penalty of a dropped job
+	F            uint                           // fan-out
+	MaxHops      uint                           // maximum number of hops
+	ProbeOptions scheduler_service.ProbeOptions // how machines are sampled and their loads compared
+	table        *learningTable
+}
+
+// learningTable holds the value of every action in every state met so far
+type learningTable struct {
+	entries map[string]map[string]types.LearningEntry
+	mutex   sync.Mutex
+}
+
+// learner is implemented by the schedulers that learn a policy which can be exported and imported
+type learner interface {
+	exportTable() *types.LearningTable
+	importTable(table *types.LearningTable) error
+}
+
+func newLearningScheduler(parameters Parameters) (Scheduler, error) {
+	options, err := getProbeOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	s := &LearningScheduler{
+		Algorithm:    parameters.GetString("Algorithm"),
+		State:        parameters.GetString("State"),
+		Reward:       parameters.GetString("Reward"),
+		Alpha:        parameters.GetFloat("Alpha"),
+		Gamma:        parameters.GetFloat("Gamma"),
+		Epsilon:      parameters.GetFloat("Epsilon"),
+		DropPenalty:  parameters.GetFloat("DropPenalty"),
+		F:            parameters.GetUint("F"),
+		MaxHops:      parameters.GetUint("MaxHops"),
+		ProbeOptions: options,
+		table:        &learningTable{entries: map[string]map[string]types.LearningEntry{}},
+	}
+
+	if s.Algorithm != LearningAlgorithmQLearning && s.Algorithm != LearningAlgorithmBandit {
+		return nil, BadSchedulerParameters{parameter: "Algorithm", reason: fmt.Sprintf("must be one of %v", LearningAlgorithms)}
+	}
+	if _, ok := learningStates[s.State]; !ok {
+		return nil, BadSchedulerParameters{parameter: "State", reason: fmt.Sprintf("must be one of %v", getLearningNames(learningStates))}
+	}
+	if _, ok := learningRewards[s.Reward]; !ok {
+		return nil, BadSchedulerParameters{parameter: "Reward", reason: fmt.Sprintf("must be one of %v", getLearningNames(learningRewards))}
+	}
+	for name, value := range map[string]float64{"Alpha": s.Alpha, "Gamma": s.Gamma, "Epsilon": s.Epsilon} {
+		if value < 0 || value > 1 {
+			return nil, BadSchedulerParameters{parameter: name, reason: "must be between 0 and 1"}
+		}
+	}
+
+	return s, nil
+}
+
+func (s LearningScheduler) GetFullName() string {
+	return fmt.Sprintf("%s(%s, %s, %s, %.2f, %.2f, %.2f, %.2f, %d, %d%s)", LearningSchedulerName, s.Algorithm, s.State, s.Reward, s.Alpha, s.Gamma, s.Epsilon, s.DropPenalty, s.F, s.MaxHops, getProbeOptionsFullName(s.ProbeOptions))
+}
+
+func (s LearningScheduler) GetScheduler() *types.SchedulerDescriptor {
+	return &types.SchedulerDescriptor{
+		Name: LearningSchedulerName,
+		Parameters: map[string]interface{}{
+			"Algorithm":      s.Algorithm,
+			"State":          s.State,
+			"Reward":         s.Reward,
+			"Alpha":          s.Alpha,
+			"Gamma":          s.Gamma,
+			"Epsilon":        s.Epsilon,
+			"DropPenalty":    s.DropPenalty,
+			"F":              s.F,
+			"MaxHops":        s.MaxHops,
+			"LoadComparison": s.ProbeOptions.LoadComparison,
+			"Sampling":       s.ProbeOptions.Sampling,
+			"MaxPing":        s.ProbeOptions.MaxPing.String(),
+		},
+		State: map[string]interface{}{
+			"States": s.table.size(),
+		},
+	}
+}
+
+// Schedule a service request. This call is blocking until the job has been executed locally or externally.
+func (s LearningScheduler) Schedule(req *types.ServiceRequest) (*JobResult, error) {
+	log.Log.Debugf("[R#%d] Scheduling job %s", req.Id, req.ServiceName)
+	currentLoad := memdb.GetTotalRunningFunctions()
+	startedScheduling := time.Now()
+	timingsStart := types.TimingsStart{ArrivedAt: &startedScheduling}
+
+	jobMustExecutedHere := req.External && req.ExternalJobRequest.Hops >= int(s.MaxHops)
+	req.Trace.AddCheck("jobMustExecutedHere", jobMustExecutedHere, "maxHops=%d", s.MaxHops)
+	if jobMustExecutedHere {
+		return executeJobLocally(req, &timingsStart)
+	}
+
+	state := learningStates[s.State](req, currentLoad)
+	action := s.table.chooseAction(state, s.Epsilon)
+
+	log.Log.Debugf("[R#%d] state=%s action=%s", req.Id, state, action)
+	req.Trace.SetReason("learned action %s in state %s", action, state)
+
+	var result *JobResult
+	var err error
+	switch action {
+	case LearningActionForwardRandom:
+		result, err = s.forwardToRandom(req, &timingsStart)
+	case LearningActionForwardProbed:
+		result, err = s.forwardToProbed(req, currentLoad, &timingsStart)
+	default:
+		result, err = executeJobLocally(req, &timingsStart)
+	}
+
+	// dry-run jobs are not executed, their outcome would teach that every action costs nothing
+	if req.DryRun {
+		return result, err
+	}
+
+	// the reward is the measured total time of the job, the drop penalty if it has been dropped
+	dropped := err != nil || result == nil || result.Response == nil || result.Response.StatusCode >= 500
+	totalTime := 0.0
+	if !dropped {
+		utils.ComputeTimings(result.TimingsStart, result.Timings)
+		totalTime = *result.Timings.TotalTime
+	}
+	reward := learningRewards[s.Reward](dropped, totalTime, s.DropPenalty)
+
+	gamma := s.Gamma
+	if s.Algorithm == LearningAlgorithmBandit {
+		gamma = 0
+	}
+	nextState := learningStates[s.State](req, memdb.GetTotalRunningFunctions())
+	s.table.update(state, action, reward, nextState, s.Alpha, gamma)
+
+	return result, err
+}
+
+func (s LearningScheduler) forwardToRandom(req *types.ServiceRequest, timingsStart *types.TimingsStart) (*JobResult, error) {
+	machines, err := discovery.GetNRandomMachines(1, getVisitedMachines(req))
+	if err != nil || len(machines) == 0 {
+		log.Log.Debugf("[R#%d] No random machine, executing locally", req.Id)
+		return executeJobLocally(req, timingsStart)
+	}
+	return executeJobExternally(req, machines[0], timingsStart)
+}
+
+func (s LearningScheduler) forwardToProbed(req *types.ServiceRequest, currentLoad uint, timingsStart *types.TimingsStart) (*JobResult, error) {
+	// save time
+	startedProbingTime := time.Now()
+	timingsStart.StartedProbingAt = &startedProbingTime
+	// get N Random machines and ask them for load and pick the least loaded
+	leastLoaded, probingMessages, _, err := scheduler_service.GetLeastLoadedMachineOfNRandom(s.F, currentLoad, false, withTrace(s.ProbeOptions, req), getVisitedMachines(req))
+	// save time
+	endProbingTime := time.Now()
+	timingsStart.EndedProbingAt = &endProbingTime
+
+	var result *JobResult
+	if err != nil {
+		log.Log.Debugf("[R#%d] Error in retrieving machines: %s", req.Id, err.Error())
+		req.Trace.SetReason("no peer can take the job: %s", err.Error())
+		result, err = executeJobLocally(req, timingsStart)
+	} else {
+		result, err = executeJobExternally(req, leastLoaded, timingsStart)
+	}
+
+	if result != nil {
+		result.ProbingMessages = probingMessages
+	}
+	return result, err
+}
+
+func (s LearningScheduler) exportTable() *types.LearningTable {
+	s.table.mutex.Lock()
+	defer s.table.mutex.Unlock()
+
+	table := &types.LearningTable{Scheduler: s.GetFullName(), Entries: map[string]map[string]types.LearningEntry{}}
+	for state, actions := range s.table.entries {
+		table.Entries[state] = map[string]types.LearningEntry{}
+		for action, entry := range actions {
+			table.Entries[state][action] = entry
+		}
+	}
+	return table
+}
+
+func (s LearningScheduler) importTable(table *types.LearningTable) error {
+	entries := map[string]map[string]types.LearningEntry{}
+	for state, actions := range table.Entries {
+		entries[state] = map[string]types.LearningEntry{}
+		for action, entry := range actions {
+			if !utils.StringInArray(action, LearningActions) {
+				return BadLearningTable{fmt.Sprintf("action %s of state %s must be one of %v", action, state, LearningActions)}
+			}
+			entries[state][action] = entry
+		}
+	}
+
+	s.table.mutex.Lock()
+	s.table.entries = entries
+	s.table.mutex.Unlock()
+	return nil
+}
+
+/*
+ * Table
+ */
+
+// chooseAction picks a random action with probability epsilon, otherwise the one with the greatest value. Actions never
+// taken have value 0, which is optimistic with negative rewards, so they are tried first.
+func (t *learningTable) chooseAction(state string, epsilon float64) string {
+	if utils.GetRandomFloat() < epsilon {
+		return LearningActions[utils.GetRandomInteger(len(LearningActions))]
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var best []string
+	for _, action := range LearningActions {
+		value := t.entries[state][action].Value
+		if len(best) == 0 || value > t.entries[state][best[0]].Value {
+			best = []string{action}
+		} else if value == t.entries[state][best[0]].Value {
+			best = append(best, action)
+		}
+	}
+	return best[utils.GetRandomInteger(len(best))]
+}
+
+// update moves the value of the action in the state towards the reward plus the discounted value of the next state
+func (t *learningTable) update(state string, action string, reward float64, nextState string, alpha float64, gamma float64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	target := reward
+	if gamma > 0 {
+		nextValue := 0.0
+		for i, a := range LearningActions {
+			if v := t.entries[nextState][a].Value; i == 0 || v > nextValue {
+				nextValue = v
+			}
+		}
+		target += gamma * nextValue
+	}
+
+	if _, ok := t.entries[state]; !ok {
+		t.entries[state] = map[string]types.LearningEntry{}
+	}
+	entry := t.entries[state][action]
+	entry.Value += alpha * (target - entry.Value)
+	entry.Visits++
+	t.entries[state][action] = entry
+}
+
+func (t *learningTable) size() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.entries)
+}
+
+/*
+ * Learning tables
+ */
+
+// GetLearningTable returns the table learned by the scheduler of the function, or by the current scheduler if function
+// is empty or has no scheduler of its own
+func GetLearningTable(function string) (*types.LearningTable, error) {
+	sched := acquireSchedulerForFunction(function)
+	defer sched.release()
+
+	l, ok := sched.Scheduler.(learner)
+	if !ok {
+		return nil, NotLearningScheduler{sched.GetFullName()}
+	}
+	return l.exportTable(), nil
+}
+
+// SetLearningTable replaces the table of the scheduler of the function, or of the current scheduler if function is
+// empty or has no scheduler of its own
+func SetLearningTable(function string, table *types.LearningTable) error {
+	sched := acquireSchedulerForFunction(function)
+	defer sched.release()
+
+	l, ok := sched.Scheduler.(learner)
+	if !ok {
+		return NotLearningScheduler{sched.GetFullName()}
+	}
+	return l.importTable(table)
+}
+
+func getLearningNames(m interface{}) []string {
+	var names []string
+	switch v := m.(type) {
+	case map[string]func(req *types.ServiceRequest, load uint) string:
+		for name := range v {
+			names = append(names, name)
+		}
+	case map[string]func(dropped bool, totalTime float64, dropPenalty float64) float64:
+		for name := range v {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -19,7 +19,6 @@
 package scheduler
 
 import (
-	"fmt"
 	"scheduler/log"
 	"scheduler/memdb"
 	"scheduler/types"
@@ -28,10 +27,20 @@ import (
 
 const NoSchedulingSchedulerName = "NoScheduler"
 
+func init() {
+	Register(NoSchedulingSchedulerName, "Execute every job locally", []types.SchedulerParameterInfo{
+		{Name: "Loss", Type: ParameterTypeBool, Default: true, Description: "discard the job if there are no free slots"},
+	}, newNoSchedulingScheduler)
+}
+
 type NoSchedulingScheduler struct {
 	Loss bool // do not use the queue
 }
 
+func newNoSchedulingScheduler(parameters Parameters) (Scheduler, error) {
+	return &NoSchedulingScheduler{Loss: parameters.GetBool("Loss")}, nil
+}
+
 func (NoSchedulingScheduler) GetFullName() string {
 	return NoSchedulingSchedulerName
 }
@@ -39,7 +48,7 @@ func (NoSchedulingScheduler) GetFullName() string {
 func (s NoSchedulingScheduler) GetScheduler() *types.SchedulerDescriptor {
 	return &types.SchedulerDescriptor{
 		Name:       NoSchedulingSchedulerName,
-		Parameters: []string{fmt.Sprintf("%t", s.Loss)},
+		Parameters: map[string]interface{}{"Loss": s.Loss},
 	}
 }
 
@@ -0,0 +1,211 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"scheduler/types"
+	"strconv"
+	"time"
+)
+
+// Types of the scheduler parameters
+const (
+	ParameterTypeUint     = "uint"
+	ParameterTypeInt      = "int"
+	ParameterTypeFloat    = "float"
+	ParameterTypeBool     = "bool"
+	ParameterTypeDuration = "duration" // a string like 10s, 200ms
+	ParameterTypeIP       = "ip"
+	ParameterTypeString   = "string"
+)
+
+// Parameters are the validated parameters of a scheduler, the value of every parameter is already converted to the Go
+// type of the parameter declared in the registry, so getters never fail.
+type Parameters map[string]interface{}
+
+func (p Parameters) GetUint(name string) uint {
+	v, _ := p[name].(uint)
+	return v
+}
+
+func (p Parameters) GetInt(name string) int {
+	v, _ := p[name].(int)
+	return v
+}
+
+func (p Parameters) GetFloat(name string) float64 {
+	v, _ := p[name].(float64)
+	return v
+}
+
+func (p Parameters) GetBool(name string) bool {
+	v, _ := p[name].(bool)
+	return v
+}
+
+func (p Parameters) GetDuration(name string) time.Duration {
+	v, _ := p[name].(time.Duration)
+	return v
+}
+
+func (p Parameters) GetString(name string) string {
+	v, _ := p[name].(string)
+	return v
+}
+
+// parseParameters validates the parameters in the descriptor against the declared ones, filling the default values
+func parseParameters(declared []types.SchedulerParameterInfo, sched *types.SchedulerDescriptor) (Parameters, error) {
+	passed := sched.Parameters
+
+	// name the positional parameters of the old format following the declaration order
+	if sched.PositionalParameters != nil {
+		if len(sched.PositionalParameters) > len(declared) {
+			return nil, BadSchedulerParameters{
+				reason: fmt.Sprintf("passed %d parameters but at most %d are accepted", len(sched.PositionalParameters), len(declared)),
+			}
+		}
+		passed = make(map[string]interface{})
+		for i, value := range sched.PositionalParameters {
+			passed[declared[i].Name] = value
+		}
+	}
+
+	// check for unknown parameters
+	for name := range passed {
+		known := false
+		for _, param := range declared {
+			if param.Name == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, BadSchedulerParameters{parameter: name, reason: "unknown parameter"}
+		}
+	}
+
+	out := make(Parameters)
+	for _, param := range declared {
+		value, present := passed[param.Name]
+		if !present || value == nil {
+			if param.Default == nil {
+				return nil, BadSchedulerParameters{parameter: param.Name, reason: "missing required parameter"}
+			}
+			value = param.Default
+		}
+
+		parsed, err := parseParameterValue(param.Type, value)
+		if err != nil {
+			return nil, BadSchedulerParameters{parameter: param.Name, reason: err.Error()}
+		}
+		out[param.Name] = parsed
+	}
+
+	return out, nil
+}
+
+// parseParameterValue converts the passed value, which comes from a decoded json, to the Go type of the parameter
+func parseParameterValue(paramType string, value interface{}) (interface{}, error) {
+	switch paramType {
+	case ParameterTypeUint, ParameterTypeInt:
+		var n float64
+		switch v := value.(type) {
+		case float64:
+			n = v
+		case int:
+			n = float64(v)
+		case uint:
+			n = float64(v)
+		case string:
+			i, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s is not a valid integer", v)
+			}
+			n = float64(i)
+		default:
+			return nil, fmt.Errorf("expected an integer")
+		}
+		if n != math.Trunc(n) {
+			return nil, fmt.Errorf("%v is not an integer", n)
+		}
+		if paramType == ParameterTypeUint {
+			if n < 0 || n > math.MaxUint32 {
+				return nil, fmt.Errorf("%v is not a valid unsigned integer", n)
+			}
+			return uint(n), nil
+		}
+		return int(n), nil
+	case ParameterTypeFloat:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s is not a valid number", v)
+			}
+			return f, nil
+		}
+		return nil, fmt.Errorf("expected a number")
+	case ParameterTypeBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s is not a valid boolean", v)
+			}
+			return b, nil
+		}
+		return nil, fmt.Errorf("expected a boolean")
+	case ParameterTypeDuration:
+		switch v := value.(type) {
+		case time.Duration:
+			return v, nil
+		case string:
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s is not a valid duration, use for example 10s or 200ms", v)
+			}
+			if d < 0 {
+				return nil, fmt.Errorf("duration cannot be negative")
+			}
+			return d, nil
+		}
+		return nil, fmt.Errorf("expected a duration string like 10s or 200ms")
+	case ParameterTypeIP:
+		v, ok := value.(string)
+		// an empty ip tells that the parameter is not set
+		if !ok || (v != "" && net.ParseIP(v) == nil) {
+			return nil, fmt.Errorf("expected a valid ip address")
+		}
+		return v, nil
+	case ParameterTypeString:
+		v, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string")
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("parameter type %s is not supported", paramType)
+}
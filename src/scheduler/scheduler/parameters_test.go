@@ -0,0 +1,186 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"encoding/json"
+	"reflect"
+	"scheduler/types"
+	"testing"
+	"time"
+)
+
+var testParameters = []types.SchedulerParameterInfo{
+	{Name: "F", Type: ParameterTypeUint, Default: 1},
+	{Name: "T", Type: ParameterTypeUint},
+	{Name: "Loss", Type: ParameterTypeBool, Default: true},
+	{Name: "Lease", Type: ParameterTypeDuration, Default: "10s"},
+}
+
+func TestParseParameterValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		paramType string
+		value     interface{}
+		expected  interface{}
+		fails     bool
+	}{
+		{"uint from json number", ParameterTypeUint, 3.0, uint(3), false},
+		{"uint from string", ParameterTypeUint, "3", uint(3), false},
+		{"uint from int", ParameterTypeUint, 3, uint(3), false},
+		{"uint negative", ParameterTypeUint, -1.0, nil, true},
+		{"uint not integer", ParameterTypeUint, 1.5, nil, true},
+		{"uint bad string", ParameterTypeUint, "three", nil, true},
+		{"uint bad type", ParameterTypeUint, true, nil, true},
+		{"int negative", ParameterTypeInt, -2.0, -2, false},
+		{"float from json number", ParameterTypeFloat, 0.5, 0.5, false},
+		{"float from string", ParameterTypeFloat, "0.5", 0.5, false},
+		{"float bad string", ParameterTypeFloat, "half", nil, true},
+		{"bool", ParameterTypeBool, false, false, false},
+		{"bool from string", ParameterTypeBool, "true", true, false},
+		{"bool bad string", ParameterTypeBool, "yes", nil, true},
+		{"duration from string", ParameterTypeDuration, "200ms", 200 * time.Millisecond, false},
+		{"duration negative", ParameterTypeDuration, "-1s", nil, true},
+		{"duration without unit", ParameterTypeDuration, "10", nil, true},
+		{"ip", ParameterTypeIP, "192.168.1.1", "192.168.1.1", false},
+		{"ip empty", ParameterTypeIP, "", "", false},
+		{"ip bad", ParameterTypeIP, "192.168.1", nil, true},
+		{"string", ParameterTypeString, "group", "group", false},
+		{"string bad type", ParameterTypeString, 1.0, nil, true},
+		{"unknown type", "complex", 1.0, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := parseParameterValue(test.paramType, test.value)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			if value != test.expected {
+				t.Fatalf("expected %v (%T), got %v (%T)", test.expected, test.expected, value, value)
+			}
+		})
+	}
+}
+
+func TestParseParameters(t *testing.T) {
+	tests := []struct {
+		name       string
+		descriptor string
+		expected   Parameters
+		fails      bool
+	}{
+		{
+			name:       "named with defaults",
+			descriptor: `{"name": "Test", "parameters": {"T": 2}}`,
+			expected:   Parameters{"F": uint(1), "T": uint(2), "Loss": true, "Lease": 10 * time.Second},
+		},
+		{
+			name:       "named overriding defaults",
+			descriptor: `{"name": "Test", "parameters": {"F": 3, "T": 2, "Loss": false, "Lease": "1m"}}`,
+			expected:   Parameters{"F": uint(3), "T": uint(2), "Loss": false, "Lease": time.Minute},
+		},
+		{
+			name:       "null value takes the default",
+			descriptor: `{"name": "Test", "parameters": {"F": null, "T": 2}}`,
+			expected:   Parameters{"F": uint(1), "T": uint(2), "Loss": true, "Lease": 10 * time.Second},
+		},
+		{
+			name:       "positional in declaration order",
+			descriptor: `{"name": "Test", "parameters": ["3", "2", "false"]}`,
+			expected:   Parameters{"F": uint(3), "T": uint(2), "Loss": false, "Lease": 10 * time.Second},
+		},
+		{
+			name:       "positional missing required",
+			descriptor: `{"name": "Test", "parameters": ["3"]}`,
+			fails:      true,
+		},
+		{
+			name:       "positional too many",
+			descriptor: `{"name": "Test", "parameters": ["3", "2", "false", "1s", "extra"]}`,
+			fails:      true,
+		},
+		{
+			name:       "missing required",
+			descriptor: `{"name": "Test", "parameters": {"F": 3}}`,
+			fails:      true,
+		},
+		{
+			name:       "no parameters",
+			descriptor: `{"name": "Test"}`,
+			fails:      true,
+		},
+		{
+			name:       "unknown parameter",
+			descriptor: `{"name": "Test", "parameters": {"T": 2, "X": 1}}`,
+			fails:      true,
+		},
+		{
+			name:       "bad value",
+			descriptor: `{"name": "Test", "parameters": {"T": -2}}`,
+			fails:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var descriptor types.SchedulerDescriptor
+			if err := json.Unmarshal([]byte(test.descriptor), &descriptor); err != nil {
+				t.Fatalf("cannot decode descriptor: %s", err.Error())
+			}
+
+			parameters, err := parseParameters(testParameters, &descriptor)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", parameters)
+				}
+				if _, ok := err.(BadSchedulerParameters); !ok {
+					t.Fatalf("expected BadSchedulerParameters, got %T", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			if !reflect.DeepEqual(parameters, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, parameters)
+			}
+		})
+	}
+}
+
+// TestRegisteredParameters checks that the default values declared by every registered scheduler are valid
+func TestRegisteredParameters(t *testing.T) {
+	for _, info := range GetAvailableSchedulers() {
+		for _, param := range info.Parameters {
+			if param.Default == nil {
+				continue
+			}
+			if _, err := parseParameterValue(param.Type, param.Default); err != nil {
+				t.Errorf("default of parameter %s of %s is not valid: %s", param.Name, info.Name, err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,337 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"fmt"
+	"scheduler/config"
+	"scheduler/log"
+	"scheduler/memdb"
+	"scheduler/metrics"
+	"scheduler/scheduler_service"
+	"scheduler/types"
+	"sync"
+	"time"
+)
+
+const AdaptivePowerOfNSchedulerName = "AdaptivePowerOfNScheduler"
+
+func init() {
+	Register(AdaptivePowerOfNSchedulerName, "Like PowerOfNScheduler but the threshold T is tuned online from the probes outcome, the local waiting time and the forwarding overhead", []types.SchedulerParameterInfo{
+		{Name: "F", Type: ParameterTypeUint, Default: 1, Description: "fan-out, number of nodes to probe"},
+		{Name: "T", Type: ParameterTypeUint, Default: 2, Description: "initial threshold, load from which probing starts"},
+		{Name: "MinT", Type: ParameterTypeUint, Default: 1, Description: "minimum threshold"},
+		{Name: "MaxT", Type: ParameterTypeUint, Default: 0, Description: "maximum threshold, 0 for the maximum number of running functions"},
+		{Name: "Window", Type: ParameterTypeUint, Default: 20, Description: "number of scheduled jobs between two adjustments of the threshold"},
+		{Name: "MinProbeSuccess", Type: ParameterTypeFloat, Default: 0.5, Description: "rate of probes finding a less loaded node below which the threshold is raised"},
+		{Name: "Loss", Type: ParameterTypeBool, Default: true, Description: "discard job if queue is full"},
+		{Name: "MaxHops", Type: ParameterTypeUint, Default: 1, Description: "maximum number of hops"},
+		loadComparisonParameterInfo,
+		samplingParameterInfo,
+		maxPingParameterInfo,
+		failurePolicyParameterInfo,
+		maxRetriesParameterInfo,
+	}, newAdaptivePowerOfNScheduler)
+}
+
+type AdaptivePowerOfNScheduler struct {
+	F               uint                           // fan-out
+	T               uint                           // initial threshold
+	MinT            uint                           // minimum threshold
+	MaxT            uint                           // maximum threshold, 0 for the maximum number of running functions
+	Window          uint                           // number of scheduled jobs between two adjustments
+	MinProbeSuccess float64                        // probe success rate below which the threshold is raised
+	Loss            bool                           // discard job if queue is full
+	MaxHops         uint                           // maximum number of hops
+	ProbeOptions    scheduler_service.ProbeOptions // how machines are sampled and their loads compared
+	FailureOptions  FailureOptions                 // what to do when the peer cannot execute the job
+	owner           string                         // who uses the scheduler, for labelling the threshold metric
+	tuner           *thresholdTuner
+}
+
+// thresholdSample is the outcome of the scheduling of a job, as seen by the tuner
+type thresholdSample struct {
+	probed         bool    // the threshold was hit and nodes have been probed
+	probeSucceeded bool    // a less loaded node has been found
+	forwarded      bool    // the job has been sent to another node
+	overhead       float64 // seconds spent in probing and forwarding the job, apart from its execution
+	rejected       bool    // the job could not be executed locally
+	wait           float64 // seconds the job waited locally before its execution
+}
+
+// thresholdTuner collects the samples of a window and adjusts the threshold when the window is complete
+type thresholdTuner struct {
+	threshold     uint
+	samples       uint
+	probes        uint
+	probeFailures uint
+	rejections    uint
+	localJobs     uint
+	localWait     float64
+	forwardedJobs uint
+	overhead      float64
+	retired       bool // the scheduler has been replaced, the threshold is not published anymore
+	mutex         sync.Mutex
+}
+
+func newAdaptivePowerOfNScheduler(parameters Parameters) (Scheduler, error) {
+	options, err := getProbeOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	failure, err := getFailureOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	s := &AdaptivePowerOfNScheduler{
+		F:               parameters.GetUint("F"),
+		T:               parameters.GetUint("T"),
+		MinT:            parameters.GetUint("MinT"),
+		MaxT:            parameters.GetUint("MaxT"),
+		Window:          parameters.GetUint("Window"),
+		MinProbeSuccess: parameters.GetFloat("MinProbeSuccess"),
+		Loss:            parameters.GetBool("Loss"),
+		MaxHops:         parameters.GetUint("MaxHops"),
+		ProbeOptions:    options,
+		FailureOptions:  failure,
+		owner:           getSchedulerOwner(parameters),
+	}
+
+	if s.Window == 0 {
+		return nil, BadSchedulerParameters{parameter: "Window", reason: "must be positive"}
+	}
+	if s.MinProbeSuccess < 0 || s.MinProbeSuccess > 1 {
+		return nil, BadSchedulerParameters{parameter: "MinProbeSuccess", reason: "must be between 0 and 1"}
+	}
+	if s.T < s.MinT || (s.MaxT > 0 && (s.T > s.MaxT || s.MinT > s.MaxT)) {
+		return nil, BadSchedulerParameters{parameter: "T", reason: "must be between MinT and MaxT"}
+	}
+
+	s.tuner = &thresholdTuner{threshold: s.T}
+	return s, nil
+}
+
+func (s AdaptivePowerOfNScheduler) GetFullName() string {
+	return fmt.Sprintf("%s(%d, %d, %d, %d, %d, %.2f, %t, %d%s%s)", AdaptivePowerOfNSchedulerName, s.F, s.T, s.MinT, s.MaxT, s.Window, s.MinProbeSuccess, s.Loss, s.MaxHops, getProbeOptionsFullName(s.ProbeOptions), getFailureOptionsFullName(s.FailureOptions))
+}
+
+func (s AdaptivePowerOfNScheduler) GetScheduler() *types.SchedulerDescriptor {
+	return &types.SchedulerDescriptor{
+		Name: AdaptivePowerOfNSchedulerName,
+		Parameters: map[string]interface{}{
+			"F":               s.F,
+			"T":               s.T,
+			"MinT":            s.MinT,
+			"MaxT":            s.MaxT,
+			"Window":          s.Window,
+			"MinProbeSuccess": s.MinProbeSuccess,
+			"Loss":            s.Loss,
+			"MaxHops":         s.MaxHops,
+			"LoadComparison":  s.ProbeOptions.LoadComparison,
+			"Sampling":        s.ProbeOptions.Sampling,
+			"MaxPing":         s.ProbeOptions.MaxPing.String(),
+			"FailurePolicy":   s.FailureOptions.Policy,
+			"MaxRetries":      s.FailureOptions.MaxRetries,
+		},
+		State: map[string]interface{}{
+			"EffectiveT": s.tuner.getThreshold(),
+		},
+	}
+}
+
+// Schedule a service request. This call is blocking until the job has been executed locally or externally.
+func (s AdaptivePowerOfNScheduler) Schedule(req *types.ServiceRequest) (*JobResult, error) {
+	log.Log.Debugf("[R#%d] Scheduling job %s", req.Id, req.ServiceName)
+	currentLoad := memdb.GetTotalRunningFunctions()
+	startedScheduling := time.Now()
+	timingsStart := types.TimingsStart{ArrivedAt: &startedScheduling}
+
+	threshold := getPriorityThreshold(s.tuner.getThreshold(), req.Priority)
+	balancingHit := currentLoad >= threshold
+	req.Trace.AddCheck("balancingHit", balancingHit, "load=%d threshold=%d priority=%d", currentLoad, threshold, req.Priority)
+	jobMustExecutedHere := req.External && req.ExternalJobRequest.Hops >= int(s.MaxHops)
+	req.Trace.AddCheck("jobMustExecutedHere", jobMustExecutedHere, "maxHops=%d", s.MaxHops)
+
+	log.Log.Debugf("[R#%d] balancingHit %t (T=%d) - jobMustExecutedHere %t", req.Id, balancingHit, threshold, jobMustExecutedHere)
+
+	// check if the balancing condition is hit
+	if balancingHit && !jobMustExecutedHere {
+		// save time
+		startedProbingTime := time.Now()
+		timingsStart.StartedProbingAt = &startedProbingTime
+		// get N Random machines and ask them for load and rank the ones less loaded than us
+		candidates, probingMessages, _, err := scheduler_service.GetLessLoadedMachinesOfNRandom(s.F, currentLoad, !s.Loss, withTrace(s.ProbeOptions, req), getVisitedMachines(req))
+		// save time
+		endProbingTime := time.Now()
+		timingsStart.EndedProbingAt = &endProbingTime
+
+		var result *JobResult
+		var sample thresholdSample
+		if err != nil {
+			log.Log.Debugf("[R#%d] Error in retrieving machines: %s", req.Id, err.Error())
+			req.Trace.SetReason("no peer can take the job: %s", err.Error())
+			// no machine less loaded than us, we are obliged to run the job in this machine or discard the job
+			// if we cannot handle it
+			result, err = executeJobLocally(req, &timingsStart)
+			sample = localSample(result, err, &timingsStart)
+		} else {
+			result, err = executeJobExternallyWithFallback(req, candidates, &timingsStart, s.FailureOptions)
+			sample = forwardSample(result, startedProbingTime)
+			sample.probeSucceeded = true
+		}
+		sample.probed = true
+		if !req.DryRun {
+			s.adjust(sample)
+		}
+
+		if result != nil {
+			result.ProbingMessages = probingMessages
+		}
+		return result, err
+	}
+
+	result, err := executeJobLocally(req, &timingsStart)
+	// dry-run jobs are not executed, their outcome tells nothing about the threshold
+	if !req.DryRun {
+		s.adjust(localSample(result, err, &timingsStart))
+	}
+	return result, err
+}
+
+// adjust records the sample and, when the window is complete, moves the threshold of one step: up if probing is mostly
+// useless or forwarding costs more than waiting here, down if jobs are rejected or forwarding costs less than waiting.
+func (s AdaptivePowerOfNScheduler) adjust(sample thresholdSample) {
+	t := s.tuner
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.samples++
+	if sample.probed {
+		t.probes++
+		if !sample.probeSucceeded {
+			t.probeFailures++
+		}
+	}
+	if sample.forwarded {
+		t.forwardedJobs++
+		t.overhead += sample.overhead
+	} else if sample.rejected {
+		t.rejections++
+	} else {
+		t.localJobs++
+		t.localWait += sample.wait
+	}
+
+	if t.samples < s.Window {
+		return
+	}
+
+	maxT := s.MaxT
+	if maxT == 0 {
+		maxT = config.Configuration.GetRunningFunctionMax()
+	}
+
+	raise := false
+	lower := false
+	probeSuccess := 1.0
+	if t.probes > 0 {
+		probeSuccess = float64(t.probes-t.probeFailures) / float64(t.probes)
+	}
+	switch {
+	case t.probes > 0 && probeSuccess < s.MinProbeSuccess:
+		raise = true
+	case t.rejections > 0:
+		lower = true
+	case t.forwardedJobs > 0 && t.localJobs > 0:
+		meanOverhead := t.overhead / float64(t.forwardedJobs)
+		meanWait := t.localWait / float64(t.localJobs)
+		raise = meanOverhead > meanWait
+		lower = meanOverhead < meanWait
+	}
+
+	previous := t.threshold
+	if raise && t.threshold < maxT {
+		t.threshold++
+	}
+	if lower && t.threshold > s.MinT {
+		t.threshold--
+	}
+
+	log.Log.Debugf("Adaptive threshold %d -> %d: probeSuccess=%.2f rejections=%d forwarded=%d local=%d", previous, t.threshold, probeSuccess, t.rejections, t.forwardedJobs, t.localJobs)
+	if !t.retired {
+		metrics.PostAdaptiveThreshold(s.owner, t.threshold)
+	}
+
+	t.resetWindow()
+}
+
+// retire stops publishing the threshold, since the owner is now served by another scheduler
+func (s AdaptivePowerOfNScheduler) retire() {
+	s.tuner.mutex.Lock()
+	defer s.tuner.mutex.Unlock()
+
+	s.tuner.retired = true
+	metrics.RemoveAdaptiveThreshold(s.owner)
+}
+
+// resetWindow clears the samples of the window, it must be called with the mutex held
+func (t *thresholdTuner) resetWindow() {
+	t.samples = 0
+	t.probes = 0
+	t.probeFailures = 0
+	t.rejections = 0
+	t.localJobs = 0
+	t.localWait = 0
+	t.forwardedJobs = 0
+	t.overhead = 0
+}
+
+func (t *thresholdTuner) getThreshold() uint {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.threshold
+}
+
+// localSample returns the sample of a job that has been scheduled locally
+func localSample(result *JobResult, err error, timingsStart *types.TimingsStart) thresholdSample {
+	if err != nil || result == nil || timingsStart.ScheduledAt == nil {
+		return thresholdSample{rejected: true}
+	}
+
+	wait := time.Since(*timingsStart.ScheduledAt).Seconds()
+	if result.Timings != nil && result.Timings.ExecutionTime != nil {
+		wait -= *result.Timings.ExecutionTime
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return thresholdSample{wait: wait}
+}
+
+// forwardSample returns the sample of a job that has been forwarded, probing included
+func forwardSample(result *JobResult, startedProbing time.Time) thresholdSample {
+	overhead := time.Since(startedProbing).Seconds()
+	if result != nil && len(result.ExternalExecutionInfo.PeersList) > 0 && result.ExternalExecutionInfo.PeersList[0].Timings.ExecutionTime != nil {
+		overhead -= *result.ExternalExecutionInfo.PeersList[0].Timings.ExecutionTime
+	}
+	if overhead < 0 {
+		overhead = 0
+	}
+	return thresholdSample{forwarded: true, overhead: overhead}
+}
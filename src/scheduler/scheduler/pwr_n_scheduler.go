@@ -29,25 +29,65 @@ import (
 
 const PowerOfNSchedulerName = "PowerOfNScheduler"
 
+func init() {
+	Register(PowerOfNSchedulerName, "Probe F random nodes when the load is above T and forward to the least loaded", []types.SchedulerParameterInfo{
+		{Name: "F", Type: ParameterTypeUint, Default: 1, Description: "fan-out, number of nodes to probe"},
+		{Name: "T", Type: ParameterTypeUint, Default: 2, Description: "threshold, load from which probing starts"},
+		{Name: "Loss", Type: ParameterTypeBool, Default: true, Description: "discard job if queue is full"},
+		{Name: "MaxHops", Type: ParameterTypeUint, Default: 1, Description: "maximum number of hops"},
+		loadComparisonParameterInfo,
+		samplingParameterInfo,
+		maxPingParameterInfo,
+		failurePolicyParameterInfo,
+		maxRetriesParameterInfo,
+	}, newPowerOfNScheduler)
+}
+
 type PowerOfNScheduler struct {
-	F       uint // fan-out
-	T       uint // threshold
-	Loss    bool // discard job if queue is full
-	MaxHops uint // maximum number of hops
+	F              uint                           // fan-out
+	T              uint                           // threshold
+	Loss           bool                           // discard job if queue is full
+	MaxHops        uint                           // maximum number of hops
+	ProbeOptions   scheduler_service.ProbeOptions // how machines are sampled and their loads compared
+	FailureOptions FailureOptions                 // what to do when the peer cannot execute the job
+}
+
+func newPowerOfNScheduler(parameters Parameters) (Scheduler, error) {
+	options, err := getProbeOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	failure, err := getFailureOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	return &PowerOfNScheduler{
+		F:              parameters.GetUint("F"),
+		T:              parameters.GetUint("T"),
+		Loss:           parameters.GetBool("Loss"),
+		MaxHops:        parameters.GetUint("MaxHops"),
+		ProbeOptions:   options,
+		FailureOptions: failure,
+	}, nil
 }
 
 func (s PowerOfNScheduler) GetFullName() string {
-	return fmt.Sprintf("%s(%d, %d, %t, %d)", PowerOfNSchedulerName, s.F, s.T, s.Loss, s.MaxHops)
+	return fmt.Sprintf("%s(%d, %d, %t, %d%s%s)", PowerOfNSchedulerName, s.F, s.T, s.Loss, s.MaxHops, getProbeOptionsFullName(s.ProbeOptions), getFailureOptionsFullName(s.FailureOptions))
 }
 
 func (s PowerOfNScheduler) GetScheduler() *types.SchedulerDescriptor {
 	return &types.SchedulerDescriptor{
 		Name: PowerOfNSchedulerName,
-		Parameters: []string{
-			fmt.Sprintf("%d", s.F),
-			fmt.Sprintf("%d", s.T),
-			fmt.Sprintf("%t", s.Loss),
-			fmt.Sprintf("%d", s.MaxHops),
+		Parameters: map[string]interface{}{
+			"F":              s.F,
+			"T":              s.T,
+			"Loss":           s.Loss,
+			"MaxHops":        s.MaxHops,
+			"LoadComparison": s.ProbeOptions.LoadComparison,
+			"Sampling":       s.ProbeOptions.Sampling,
+			"MaxPing":        s.ProbeOptions.MaxPing.String(),
+			"FailurePolicy":  s.FailureOptions.Policy,
+			"MaxRetries":     s.FailureOptions.MaxRetries,
 		},
 	}
 }
@@ -59,8 +99,11 @@ func (s PowerOfNScheduler) Schedule(req *types.ServiceRequest) (*JobResult, erro
 	startedScheduling := time.Now()
 	timingsStart := types.TimingsStart{ArrivedAt: &startedScheduling}
 
-	balancingHit := currentLoad >= s.T
+	threshold := getPriorityThreshold(s.T, req.Priority)
+	balancingHit := currentLoad >= threshold
+	req.Trace.AddCheck("balancingHit", balancingHit, "load=%d threshold=%d priority=%d", currentLoad, threshold, req.Priority)
 	jobMustExecutedHere := req.External && req.ExternalJobRequest.Hops >= int(s.MaxHops)
+	req.Trace.AddCheck("jobMustExecutedHere", jobMustExecutedHere, "maxHops=%d", s.MaxHops)
 
 	log.Log.Debugf("balancingHit %t - jobMustExecutedHere %t", balancingHit, jobMustExecutedHere)
 
@@ -69,20 +112,27 @@ func (s PowerOfNScheduler) Schedule(req *types.ServiceRequest) (*JobResult, erro
 		// save time
 		startedProbingTime := time.Now()
 		timingsStart.StartedProbingAt = &startedProbingTime
-		// get N Random machines and ask them for load and pick the least loaded
-		leastLoaded, _, err := scheduler_service.GetLeastLoadedMachineOfNRandom(s.F, currentLoad, !s.Loss)
+		// get N Random machines and ask them for load and rank the ones less loaded than us
+		candidates, probingMessages, _, err := scheduler_service.GetLessLoadedMachinesOfNRandom(s.F, currentLoad, !s.Loss, withTrace(s.ProbeOptions, req), getVisitedMachines(req))
 		// save time
 		endProbingTime := time.Now()
 		timingsStart.EndedProbingAt = &endProbingTime
 
+		var result *JobResult
 		if err != nil {
 			log.Log.Debugf("Error in retrieving machines %s", err.Error())
+			req.Trace.SetReason("no peer can take the job: %s", err.Error())
 			// no machine less loaded than us, we are obliged to run the job in this machine or discard the job
 			// if we cannot handle it
-			return executeJobLocally(req, &timingsStart)
+			result, err = executeJobLocally(req, &timingsStart)
+		} else {
+			result, err = executeJobExternallyWithFallback(req, candidates, &timingsStart, s.FailureOptions)
 		}
 
-		return executeJobExternally(req, leastLoaded, &timingsStart)
+		if result != nil {
+			result.ProbingMessages = probingMessages
+		}
+		return result, err
 	}
 
 	return executeJobLocally(req, &timingsStart)
@@ -29,27 +29,69 @@ import (
 
 const PowerOfNSchedulerTauName = "PowerOfNSchedulerTau"
 
+func init() {
+	Register(PowerOfNSchedulerTauName, "Like PowerOfNScheduler but the forwarding decision is delayed of Tau", []types.SchedulerParameterInfo{
+		{Name: "F", Type: ParameterTypeUint, Default: 1, Description: "fan-out, number of nodes to probe"},
+		{Name: "T", Type: ParameterTypeUint, Default: 2, Description: "threshold, load from which probing starts"},
+		{Name: "Loss", Type: ParameterTypeBool, Default: true, Description: "discard job if queue is full"},
+		{Name: "MaxHops", Type: ParameterTypeUint, Default: 1, Description: "maximum number of hops"},
+		{Name: "Tau", Type: ParameterTypeDuration, Description: "time to delay the probing, e.g. 10s, 200ms"},
+		loadComparisonParameterInfo,
+		samplingParameterInfo,
+		maxPingParameterInfo,
+		failurePolicyParameterInfo,
+		maxRetriesParameterInfo,
+	}, newPowerOfNSchedulerTau)
+}
+
 type PowerOfNSchedulerTau struct {
-	F       uint          // fan-out
-	T       uint          // threshold
-	Loss    bool          // discard job if queue is full
-	MaxHops uint          // maximum number of hops
-	Tau     time.Duration // time to delay the probing
+	F              uint                           // fan-out
+	T              uint                           // threshold
+	Loss           bool                           // discard job if queue is full
+	MaxHops        uint                           // maximum number of hops
+	Tau            time.Duration                  // time to delay the probing
+	ProbeOptions   scheduler_service.ProbeOptions // how machines are sampled and their loads compared
+	FailureOptions FailureOptions                 // what to do when the peer cannot execute the job
+}
+
+func newPowerOfNSchedulerTau(parameters Parameters) (Scheduler, error) {
+	options, err := getProbeOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	failure, err := getFailureOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	return &PowerOfNSchedulerTau{
+		F:              parameters.GetUint("F"),
+		T:              parameters.GetUint("T"),
+		Loss:           parameters.GetBool("Loss"),
+		MaxHops:        parameters.GetUint("MaxHops"),
+		Tau:            parameters.GetDuration("Tau"),
+		ProbeOptions:   options,
+		FailureOptions: failure,
+	}, nil
 }
 
 func (s PowerOfNSchedulerTau) GetFullName() string {
-	return fmt.Sprintf("%s(%d, %d, %t, %d, %dms)", PowerOfNSchedulerTauName, s.F, s.T, s.Loss, s.MaxHops, s.Tau.Milliseconds())
+	return fmt.Sprintf("%s(%d, %d, %t, %d, %dms%s%s)", PowerOfNSchedulerTauName, s.F, s.T, s.Loss, s.MaxHops, s.Tau.Milliseconds(), getProbeOptionsFullName(s.ProbeOptions), getFailureOptionsFullName(s.FailureOptions))
 }
 
 func (s PowerOfNSchedulerTau) GetScheduler() *types.SchedulerDescriptor {
 	return &types.SchedulerDescriptor{
 		Name: PowerOfNSchedulerTauName,
-		Parameters: []string{
-			fmt.Sprintf("%d", s.F),
-			fmt.Sprintf("%d", s.T),
-			fmt.Sprintf("%t", s.Loss),
-			fmt.Sprintf("%d", s.MaxHops),
-			fmt.Sprintf("%dms", s.Tau.Milliseconds()),
+		Parameters: map[string]interface{}{
+			"F":              s.F,
+			"T":              s.T,
+			"Loss":           s.Loss,
+			"MaxHops":        s.MaxHops,
+			"Tau":            fmt.Sprintf("%dms", s.Tau.Milliseconds()),
+			"LoadComparison": s.ProbeOptions.LoadComparison,
+			"Sampling":       s.ProbeOptions.Sampling,
+			"MaxPing":        s.ProbeOptions.MaxPing.String(),
+			"FailurePolicy":  s.FailureOptions.Policy,
+			"MaxRetries":     s.FailureOptions.MaxRetries,
 		},
 	}
 }
@@ -61,8 +103,11 @@ func (s PowerOfNSchedulerTau) Schedule(req *types.ServiceRequest) (*JobResult, e
 	startedScheduling := time.Now()
 	timingsStart := types.TimingsStart{ArrivedAt: &startedScheduling}
 
-	balancingHit := currentLoad >= s.T
+	threshold := getPriorityThreshold(s.T, req.Priority)
+	balancingHit := currentLoad >= threshold
+	req.Trace.AddCheck("balancingHit", balancingHit, "load=%d threshold=%d priority=%d", currentLoad, threshold, req.Priority)
 	jobMustExecutedHere := req.External && req.ExternalJobRequest.Hops >= int(s.MaxHops)
+	req.Trace.AddCheck("jobMustExecutedHere", jobMustExecutedHere, "maxHops=%d", s.MaxHops)
 
 	log.Log.Debugf("[R#%d] balancingHit %t - jobMustExecutedHere %t", req.Id, balancingHit, jobMustExecutedHere)
 
@@ -71,8 +116,8 @@ func (s PowerOfNSchedulerTau) Schedule(req *types.ServiceRequest) (*JobResult, e
 		// save time
 		startedProbingTime := time.Now()
 		timingsStart.StartedProbingAt = &startedProbingTime
-		// get N Random machines and ask them for load and pick the least loaded
-		leastLoaded, _, err := scheduler_service.GetLeastLoadedMachineOfNRandom(s.F, currentLoad, !s.Loss)
+		// get N Random machines and ask them for load and rank the ones less loaded than us
+		candidates, probingMessages, _, err := scheduler_service.GetLessLoadedMachinesOfNRandom(s.F, currentLoad, !s.Loss, withTrace(s.ProbeOptions, req), getVisitedMachines(req))
 		// save time
 		endProbingTime := time.Now()
 		timingsStart.EndedProbingAt = &endProbingTime
@@ -84,14 +129,21 @@ func (s PowerOfNSchedulerTau) Schedule(req *types.ServiceRequest) (*JobResult, e
 			time.Sleep(s.Tau - probingTime)
 		}
 
+		var result *JobResult
 		if err != nil {
 			log.Log.Debugf("[R#%d] Error in retrieving machines: %s", req.Id, err.Error())
+			req.Trace.SetReason("no peer can take the job: %s", err.Error())
 			// no machine less loaded than us, we are obliged to run the job in this machine or discard the job
 			// if we cannot handle it
-			return executeJobLocally(req, &timingsStart)
+			result, err = executeJobLocally(req, &timingsStart)
+		} else {
+			result, err = executeJobExternallyWithFallback(req, candidates, &timingsStart, s.FailureOptions)
 		}
 
-		return executeJobExternally(req, leastLoaded, &timingsStart)
+		if result != nil {
+			result.ProbingMessages = probingMessages
+		}
+		return result, err
 	}
 
 	return executeJobLocally(req, &timingsStart)
@@ -0,0 +1,118 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"fmt"
+	"scheduler/types"
+	"sort"
+	"sync"
+)
+
+// Factory creates a new instance of a scheduler starting from the parameters passed in the descriptor, already
+// validated against the ones declared in the registry
+type Factory func(parameters Parameters) (Scheduler, error)
+
+type registryEntry struct {
+	info    types.SchedulerInfo
+	factory Factory
+}
+
+var registry = make(map[string]*registryEntry)
+var registryMutex sync.RWMutex
+
+// Register adds a scheduler to the registry, in such a way it can be selected with SetScheduler by using its name. This
+// is meant to be called in the init() of the file that implements the scheduler.
+func Register(name string, description string, parameters []types.SchedulerParameterInfo, factory Factory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("scheduler %s registered twice", name))
+	}
+	if parameters == nil {
+		parameters = []types.SchedulerParameterInfo{}
+	}
+	for i := range parameters {
+		parameters[i].Required = parameters[i].Default == nil
+	}
+
+	registry[name] = &registryEntry{
+		info: types.SchedulerInfo{
+			Name:        name,
+			Description: description,
+			Parameters:  parameters,
+		},
+		factory: factory,
+	}
+}
+
+// GetAvailableSchedulers returns all the registered schedulers, sorted by name
+func GetAvailableSchedulers() []types.SchedulerInfo {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	var out []types.SchedulerInfo
+	for _, entry := range registry {
+		out = append(out, entry.info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+
+	return out
+}
+
+// GetSchedulerInfo returns the registered information of the scheduler with the given name
+func GetSchedulerInfo(name string) (*types.SchedulerInfo, error) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	entry, exists := registry[name]
+	if !exists {
+		return nil, UnknownScheduler{name}
+	}
+	info := entry.info
+	return &info, nil
+}
+
+// newSchedulerFromDescriptor creates a new scheduler instance by using the factory registered with the descriptor name
+func newSchedulerFromDescriptor(sched *types.SchedulerDescriptor) (Scheduler, error) {
+	return newSchedulerFromDescriptorWith(sched, nil)
+}
+
+// newSchedulerFromDescriptorWith is like newSchedulerFromDescriptor but it passes to the factory also the internal
+// parameters, which are not declared and cannot be set through the descriptor
+func newSchedulerFromDescriptorWith(sched *types.SchedulerDescriptor, internal Parameters) (Scheduler, error) {
+	registryMutex.RLock()
+	entry, exists := registry[sched.Name]
+	registryMutex.RUnlock()
+
+	if !exists {
+		return nil, UnknownScheduler{sched.Name}
+	}
+
+	parameters, err := parseParameters(entry.info.Parameters, sched)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range internal {
+		parameters[name] = value
+	}
+
+	return entry.factory(parameters)
+}
@@ -29,27 +29,74 @@ import (
 
 const RoundRobinWithMasterSchedulerName = "RoundRobinWithMasterScheduler"
 
+func init() {
+	Register(RoundRobinWithMasterSchedulerName, "Slaves send all the jobs to the master which dispatches them with round robin", []types.SchedulerParameterInfo{
+		{Name: "Master", Type: ParameterTypeBool, Default: false, Description: "if current node is master, not used when the master is elected"},
+		{Name: "MasterIP", Type: ParameterTypeIP, Default: "", Description: "ip of master node, empty for electing it among the discovered nodes"},
+		{Name: "Loss", Type: ParameterTypeBool, Default: true, Description: "discard job if queue is full"},
+		{Name: "Lease", Type: ParameterTypeDuration, Default: "10s", Description: "time for which the elected master is kept before electing it again"},
+	}, newRoundRobinWithMasterScheduler)
+}
+
 type RoundRobinWithMasterScheduler struct {
-	Master            bool       // if current node is master
-	MasterIP          string     // ip of master node
-	Loss              bool       // discard job if queue is full
-	currentIndex      int        // current index of the round robin
-	currentIndexMutex sync.Mutex // protect race conditions on currentIndex
+	Master            bool          // if current node is master
+	MasterIP          string        // ip of master node
+	Loss              bool          // discard job if queue is full
+	Lease             time.Duration // time for which the elected master is kept
+	currentIndex      int           // current index of the round robin
+	currentIndexMutex sync.Mutex    // protect race conditions on currentIndex
+	election          *leaderElection
+}
+
+func newRoundRobinWithMasterScheduler(parameters Parameters) (Scheduler, error) {
+	s := &RoundRobinWithMasterScheduler{
+		Master:       parameters.GetBool("Master"),
+		MasterIP:     parameters.GetString("MasterIP"),
+		Loss:         parameters.GetBool("Loss"),
+		Lease:        parameters.GetDuration("Lease"),
+		currentIndex: 0,
+	}
+
+	// without a static master, it is elected among the discovered nodes
+	if s.MasterIP == "" {
+		if s.Master {
+			return nil, BadSchedulerParameters{parameter: "Master", reason: "cannot be set when the master is elected"}
+		}
+		if s.Lease == 0 {
+			return nil, BadSchedulerParameters{parameter: "Lease", reason: "must be greater than 0"}
+		}
+		s.election = newLeaderElection(s.Lease)
+	}
+
+	return s, nil
 }
 
 func (s *RoundRobinWithMasterScheduler) GetFullName() string {
+	if s.election != nil {
+		return fmt.Sprintf("%s(elected, %t, %s)", RoundRobinWithMasterSchedulerName, s.Loss, s.Lease)
+	}
 	return fmt.Sprintf("%s(%t, %s, %t)", RoundRobinWithMasterSchedulerName, s.Master, s.MasterIP, s.Loss)
 }
 
 func (s *RoundRobinWithMasterScheduler) GetScheduler() *types.SchedulerDescriptor {
-	return &types.SchedulerDescriptor{
+	descriptor := &types.SchedulerDescriptor{
 		Name: RoundRobinWithMasterSchedulerName,
-		Parameters: []string{
-			fmt.Sprintf("%t", s.Master),
-			fmt.Sprintf("%s", s.MasterIP),
-			fmt.Sprintf("%t", s.Loss),
+		Parameters: map[string]interface{}{
+			"Master":   s.Master,
+			"MasterIP": s.MasterIP,
+			"Loss":     s.Loss,
+			"Lease":    s.Lease.String(),
 		},
 	}
+	if s.election != nil {
+		leader, expiresAt := s.election.getState()
+		descriptor.State = map[string]interface{}{
+			"Leader":         leader,
+			"LeaderIsSelf":   leader != "" && leader == discovery.Configuration.MachineIp,
+			"LeaseExpiresAt": expiresAt,
+		}
+	}
+	return descriptor
 }
 
 func (s *RoundRobinWithMasterScheduler) Schedule(req *types.ServiceRequest) (*JobResult, error) {
@@ -57,43 +104,91 @@ func (s *RoundRobinWithMasterScheduler) Schedule(req *types.ServiceRequest) (*Jo
 	now := time.Now()
 	timingsStart := types.TimingsStart{ArrivedAt: &now}
 
+	if s.election != nil {
+		return s.scheduleWithElectedMaster(req, &timingsStart)
+	}
+
 	// Master node
 	if s.Master {
 		// Master node cannot schedule jobs only dispatch them
 		if !req.External {
 			return nil, JobCannotBeScheduled{}
 		}
-		// Obtain the list of all machines and select one with a round robin fashion
-		machinesIp, err := discovery.GetListOfMachines()
+		return s.dispatch(req, &timingsStart)
+	}
+
+	// Slave node
+	// If request is internal dispatch it to the master node
+	if !req.External {
+		return executeJobExternally(req, s.MasterIP, &timingsStart)
+	}
+
+	// Otherwise execute it internally
+	return executeJobLocally(req, &timingsStart)
+}
+
+// scheduleWithElectedMaster follows the current leader: the leader dispatches the jobs of its clients and of the slaves,
+// while slaves send their jobs to the leader and execute the ones dispatched to them. When the leader cannot be reached
+// it is revoked and the job is sent to the newly elected one.
+func (s *RoundRobinWithMasterScheduler) scheduleWithElectedMaster(req *types.ServiceRequest, timingsStart *types.TimingsStart) (*JobResult, error) {
+	// jobs that have already been dispatched by a leader are executed here
+	if req.External && req.ExternalJobRequest.Hops >= 2 {
+		return executeJobLocally(req, timingsStart)
+	}
+
+	for {
+		leader, err := s.election.getLeader()
 		if err != nil {
 			return nil, JobCannotBeScheduled{err.Error()}
 		}
-		if len(machinesIp) == 0 {
-			return nil, JobCannotBeScheduled{"no machine known"}
+
+		if leader == discovery.Configuration.MachineIp {
+			return s.dispatch(req, timingsStart)
 		}
 
-		// Update the id of next machine
-		s.currentIndexMutex.Lock()
-		// Check if current index is not exceeding the length of machines array
-		if s.currentIndex >= len(machinesIp) {
-			s.currentIndex = 0
+		// jobs sent by another slave which considers us the leader are executed here, the views will agree at the next
+		// election
+		if req.External {
+			return executeJobLocally(req, timingsStart)
 		}
-		pickedMachineIp := machinesIp[s.currentIndex]
-		s.currentIndex = (s.currentIndex + 1) % len(machinesIp)
-		s.currentIndexMutex.Unlock()
 
-		log.Log.Debugf("nextIndex is %d", s.currentIndex)
+		result, err := forwardJob(req, leader, timingsStart)
+		if result == nil || err == nil {
+			return result, err
+		}
+		// the client is gone, the leader is not to blame
+		if req.Context.Err() != nil {
+			return result, err
+		}
+		log.Log.Debugf("[R#%d] Leader %s cannot execute the job: %s", req.Id, leader, err.Error())
+		req.Trace.SetReason("leader %s cannot be reached", leader)
+		s.election.revoke(leader)
+	}
+}
 
-		// Schedule the job to that machine
-		return executeJobExternally(req, pickedMachineIp, &timingsStart)
+// dispatch the job to the next machine with a round robin fashion
+func (s *RoundRobinWithMasterScheduler) dispatch(req *types.ServiceRequest, timingsStart *types.TimingsStart) (*JobResult, error) {
+	// Obtain the list of all machines and select one with a round robin fashion
+	machinesIp, err := discovery.GetListOfMachines()
+	if err != nil {
+		return nil, JobCannotBeScheduled{err.Error()}
+	}
+	if len(machinesIp) == 0 {
+		return nil, JobCannotBeScheduled{"no machine known"}
 	}
 
-	// Slave node
-	// If request is internal dispatch it to the master node
-	if !req.External {
-		return executeJobExternally(req, s.MasterIP, &timingsStart)
+	// Update the id of next machine
+	s.currentIndexMutex.Lock()
+	// Check if current index is not exceeding the length of machines array
+	if s.currentIndex >= len(machinesIp) {
+		s.currentIndex = 0
 	}
+	pickedMachineIp := machinesIp[s.currentIndex]
+	s.currentIndex = (s.currentIndex + 1) % len(machinesIp)
+	s.currentIndexMutex.Unlock()
 
-	// Otherwise execute it internally
-	return executeJobLocally(req, &timingsStart)
+	log.Log.Debugf("nextIndex is %d", s.currentIndex)
+
+	// Schedule the job to that machine
+	return executeJobExternally(req, pickedMachineIp, timingsStart)
 }
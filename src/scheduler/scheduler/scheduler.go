@@ -20,13 +20,17 @@
 package scheduler
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"scheduler/config"
 	"scheduler/log"
 	"scheduler/memdb"
+	"scheduler/metrics"
+	"scheduler/scheduler_service"
 	"scheduler/types"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -34,7 +38,7 @@ import (
  * Interfaces
  */
 
-type scheduler interface {
+type Scheduler interface {
 	// GetFullName used for returning the name of the scheduler with the parameters
 	GetFullName() string
 	// GetScheduler returns the representation of the scheduler
@@ -48,13 +52,12 @@ type scheduler interface {
  * Code
  */
 
-var currentScheduler scheduler
+var currentScheduler *runningScheduler
+var currentSchedulerMutex sync.RWMutex
 
+// Start loads the scheduler from the configuration file. This is not done in init() since all the schedulers must have
+// been registered before.
 func Start() {
-
-}
-
-func init() {
 	useDefault := false
 	// try to read the configuration file
 	file, err := ioutil.ReadFile(config.GetConfigSchedulerFilePath())
@@ -71,25 +74,51 @@ func init() {
 	} else {
 		err = SetScheduler(&proposedScheduler)
 		if err != nil {
+			log.Log.Warningf("Could not set scheduler from config file, using default: %s", err.Error())
 			useDefault = true
 		}
 	}
 
 	if useDefault {
-		currentScheduler = getDefaultScheduler()
+		currentScheduler = newRunningScheduler(getDefaultScheduler())
 	} else {
 		log.Log.Debugf("Used configuration file")
 	}
 
 	log.Log.Infof("Init with '%s' scheduler", currentScheduler.GetFullName())
+
+	loadFunctionSchedulers()
+	startWorkStealing()
 }
 
 /*
  * Actions
  */
 
-func Schedule(req *types.ServiceRequest) (*JobResult, error) {
-	return currentScheduler.Schedule(req)
+// Schedule schedules the request with the scheduler of the function, or with the current scheduler if the function has
+// none. If the scheduler is swapped in the meanwhile the call completes on the scheduler used when it started. When the
+// context is done the job is abandoned, wherever it is: waiting in the queue, executing or forwarded.
+func Schedule(ctx context.Context, req *types.ServiceRequest) (*JobResult, error) {
+	req.Context = ctx
+	applyFunctionDeadline(req, time.Now())
+
+	sched := acquireSchedulerForFunction(req.ServiceName)
+	defer sched.release()
+
+	req.Trace = newDecisionTrace(req, sched.GetFullName())
+	shadowSched, shadowDecision := startShadowSchedule(req)
+
+	result, err := sched.Schedule(req)
+
+	decision := getDecision(result, err)
+	if shadowSched != nil {
+		go shadowSched.record(req, decision, shadowDecision)
+	}
+	req.Trace.Finish(decision, err)
+	recordDecisionTrace(req.Trace)
+	metrics.PostJobScheduledWithPriority(req.ServiceName, types.GetPriorityClass(req.Priority), strings.SplitN(decision, ":", 2)[0])
+
+	return result, err
 }
 
 /*
@@ -97,103 +126,51 @@ func Schedule(req *types.ServiceRequest) (*JobResult, error) {
  */
 
 func GetName() string {
-	return currentScheduler.GetFullName()
+	return getCurrentScheduler().GetFullName()
 }
 
 func GetScheduler() *types.SchedulerDescriptor {
-	return currentScheduler.GetScheduler()
+	return getCurrentScheduler().GetScheduler()
 }
 
+// SetScheduler replaces the current scheduler with a new one created by the factory registered with the descriptor name.
+// This can be done only if no function is running, otherwise use SwapScheduler.
 func SetScheduler(sched *types.SchedulerDescriptor) error {
 
 	if memdb.GetTotalRunningFunctions() != 0 {
 		return CannotChangeScheduler{}
 	}
 
-	switch sched.Name {
-	case NoSchedulingSchedulerName:
-		if len(sched.Parameters) < 1 {
-			return BadSchedulerParameters{}
-		}
-		l, err := strconv.ParseBool(sched.Parameters[0])
-		if err != nil {
-			return BadSchedulerParameters{}
-		}
-		currentScheduler = &NoSchedulingScheduler{Loss: l}
-		break
-	case ForwardSchedulerName:
-		if len(sched.Parameters) < 1 {
-			return BadSchedulerParameters{}
-		}
-		m, err := strconv.ParseUint(sched.Parameters[0], 10, 32)
-		if err != nil {
-			return BadSchedulerParameters{}
-		}
-		currentScheduler = &ForwardScheduler{
-			MaxHops: uint(m),
-		}
-		break
-	case PowerOfNSchedulerName:
-		if len(sched.Parameters) < 4 {
-			return BadSchedulerParameters{}
-		}
-		f, err1 := strconv.ParseUint(sched.Parameters[0], 10, 32)
-		t, err2 := strconv.ParseUint(sched.Parameters[1], 10, 32)
-		l, err3 := strconv.ParseBool(sched.Parameters[2])
-		m, err4 := strconv.ParseUint(sched.Parameters[3], 10, 32)
-		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
-			return BadSchedulerParameters{}
-		}
-		currentScheduler = &PowerOfNScheduler{
-			F:       uint(f),
-			T:       uint(t),
-			Loss:    l,
-			MaxHops: uint(m),
-		}
-		break
-	case PowerOfNSchedulerTauName:
-		if len(sched.Parameters) < 5 {
-			return BadSchedulerParameters{}
-		}
-		f, err1 := strconv.ParseUint(sched.Parameters[0], 10, 32)
-		T, err2 := strconv.ParseUint(sched.Parameters[1], 10, 32)
-		l, err3 := strconv.ParseBool(sched.Parameters[2])
-		m, err4 := strconv.ParseUint(sched.Parameters[3], 10, 32)
-		t, err5 := time.ParseDuration(sched.Parameters[4]) // duration: 10s, 200ms, etc.
-		if err1 != nil || err2 != nil || err3 != nil || err4 != nil || err5 != nil {
-			return BadSchedulerParameters{}
-		}
-		currentScheduler = &PowerOfNSchedulerTau{
-			F:       uint(f),
-			T:       uint(T),
-			Loss:    l,
-			MaxHops: uint(m),
-			Tau:     t,
-		}
-		break
-	case RoundRobinWithMasterSchedulerName:
-		if len(sched.Parameters) < 3 {
-			return BadSchedulerParameters{}
-		}
-		m, err1 := strconv.ParseBool(sched.Parameters[0])
-		i := sched.Parameters[1]
-		l, err2 := strconv.ParseBool(sched.Parameters[2])
-		if err1 != nil || err2 != nil {
-			return BadSchedulerParameters{}
-		}
-		currentScheduler = &RoundRobinWithMasterScheduler{
-			Master:       m,
-			MasterIP:     i,
-			Loss:         l,
-			currentIndex: 0,
-		}
-	default:
-		return BadSchedulerParameters{}
+	newScheduler, err := newSchedulerFromDescriptor(sched)
+	if err != nil {
+		return err
+	}
+
+	currentSchedulerMutex.Lock()
+	if currentScheduler != nil {
+		currentScheduler.retire()
 	}
+	currentScheduler = newRunningScheduler(newScheduler)
+	currentSchedulerMutex.Unlock()
+
 	return nil
 }
 
-func getDefaultScheduler() scheduler {
+func getCurrentScheduler() *runningScheduler {
+	currentSchedulerMutex.RLock()
+	defer currentSchedulerMutex.RUnlock()
+	return currentScheduler
+}
+
+// acquireCurrentScheduler returns the current scheduler marking a new in-flight call on it, the caller must release it
+func acquireCurrentScheduler() *runningScheduler {
+	currentSchedulerMutex.RLock()
+	defer currentSchedulerMutex.RUnlock()
+	currentScheduler.acquire()
+	return currentScheduler
+}
+
+func getDefaultScheduler() Scheduler {
 	/*
 		return NoSchedulingScheduler{
 			Loss: true,
@@ -204,5 +181,9 @@ func getDefaultScheduler() scheduler {
 		T:       2,
 		Loss:    true,
 		MaxHops: 1,
+		ProbeOptions: scheduler_service.ProbeOptions{
+			LoadComparison: scheduler_service.LoadComparisonRaw,
+			Sampling:       scheduler_service.SamplingUniform,
+		},
 	}
 }
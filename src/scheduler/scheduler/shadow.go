@@ -0,0 +1,197 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"scheduler/log"
+	"scheduler/types"
+	"sync"
+	"time"
+)
+
+// shadowDecisionsMax is the number of the last decision pairs kept
+const shadowDecisionsMax = 1000
+
+// shadowScheduler is a scheduler evaluated next to the active one: it schedules a dry-run copy of every job, so that it
+// decides what it would do without executing anything
+type shadowScheduler struct {
+	Scheduler
+	probe      bool
+	since      time.Time
+	decisions  []types.ShadowDecision // ring of the last decision pairs
+	next       int                    // index of the ring in which the next pair is written
+	total      uint64
+	agreements uint64
+	mutex      sync.Mutex
+}
+
+var shadow *shadowScheduler
+var shadowMutex sync.RWMutex
+
+/*
+ * Actions
+ */
+
+// SetShadowScheduler sets the scheduler evaluated in shadow mode, replacing the previous one. If probe is false the
+// shadow scheduler sends no probe and uses the last known loads of the peers.
+func SetShadowScheduler(sched *types.SchedulerDescriptor, probe bool) error {
+	internal := Parameters{ownerParameter: schedulerOwnerShadow}
+	if !probe {
+		internal[cachedLoadsParameter] = true
+	}
+	newScheduler, err := newSchedulerFromDescriptorWith(sched, internal)
+	if err != nil {
+		return err
+	}
+
+	shadowMutex.Lock()
+	shadow = &shadowScheduler{Scheduler: newScheduler, probe: probe, since: time.Now()}
+	shadowMutex.Unlock()
+
+	return nil
+}
+
+// RemoveShadowScheduler stops the shadow evaluation, it returns false if there was none
+func RemoveShadowScheduler() bool {
+	shadowMutex.Lock()
+	defer shadowMutex.Unlock()
+
+	if shadow == nil {
+		return false
+	}
+	shadow = nil
+	return true
+}
+
+/*
+ * Shadow info related
+ */
+
+// GetShadowStatus returns the status of the shadow evaluation, nil if there is no shadow scheduler
+func GetShadowStatus() *types.ShadowStatus {
+	s := getShadowScheduler()
+	if s == nil {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return &types.ShadowStatus{
+		Scheduler:  s.GetScheduler(),
+		FullName:   s.GetFullName(),
+		Probe:      s.probe,
+		Since:      s.since,
+		Decisions:  s.total,
+		Agreements: s.agreements,
+	}
+}
+
+// GetShadowDecisions returns the last decision pairs from the oldest, nil if there is no shadow scheduler
+func GetShadowDecisions() []types.ShadowDecision {
+	s := getShadowScheduler()
+	if s == nil {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	out := make([]types.ShadowDecision, 0, len(s.decisions))
+	if len(s.decisions) == shadowDecisionsMax {
+		out = append(out, s.decisions[s.next:]...)
+		out = append(out, s.decisions[:s.next]...)
+	} else {
+		out = append(out, s.decisions...)
+	}
+	return out
+}
+
+/*
+ * Utils
+ */
+
+func getShadowScheduler() *shadowScheduler {
+	shadowMutex.RLock()
+	defer shadowMutex.RUnlock()
+	return shadow
+}
+
+// startShadowSchedule schedules a dry-run copy of the job with the shadow scheduler, it returns the shadow scheduler and
+// the channel on which its decision is sent, nil if there is no shadow scheduler. The copy is taken now, before the job
+// is changed by its execution.
+func startShadowSchedule(req *types.ServiceRequest) (*shadowScheduler, <-chan string) {
+	s := getShadowScheduler()
+	if s == nil {
+		return nil, nil
+	}
+
+	shadowReq := *req
+	shadowReq.DryRun = true
+	shadowReq.Trace = nil
+	decision := make(chan string, 1)
+	go func() {
+		result, err := s.Schedule(&shadowReq)
+		decision <- getDecision(result, err)
+	}()
+	return s, decision
+}
+
+// record the decision pair of a job, waiting for the decision of the shadow scheduler
+func (s *shadowScheduler) record(req *types.ServiceRequest, active string, shadowDecision <-chan string) {
+	pair := types.ShadowDecision{
+		RequestId: req.Id,
+		Function:  req.ServiceName,
+		Active:    active,
+		Shadow:    <-shadowDecision,
+		At:        time.Now(),
+	}
+	pair.Agree = pair.Active == pair.Shadow
+
+	log.Log.Infof("[R#%d] Shadow decision for %s: active=%s shadow=%s agree=%t", req.Id, req.ServiceName, pair.Active, pair.Shadow, pair.Agree)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.decisions) < shadowDecisionsMax {
+		s.decisions = append(s.decisions, pair)
+	} else {
+		s.decisions[s.next] = pair
+	}
+	s.next = (s.next + 1) % shadowDecisionsMax
+	s.total++
+	if pair.Agree {
+		s.agreements++
+	}
+}
+
+// getDecision returns the decision taken for a job given its result: it is dropped if it has not been executed,
+// forwarded to the node that executed it, or executed locally
+func getDecision(result *JobResult, err error) string {
+	if result == nil || result.Response == nil || (err != nil && !result.ExternalExecution) {
+		return DecisionDrop
+	}
+	if !result.ExternalExecution {
+		return DecisionLocal
+	}
+	peers := result.ExternalExecutionInfo.PeersList
+	if len(peers) == 0 {
+		return DecisionForward
+	}
+	return DecisionForward + ":" + peers[len(peers)-1].MachineIp
+}
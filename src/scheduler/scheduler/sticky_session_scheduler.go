@@ -0,0 +1,300 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"fmt"
+	"scheduler/config"
+	"scheduler/discovery"
+	"scheduler/log"
+	"scheduler/memdb"
+	"scheduler/scheduler_service"
+	"scheduler/types"
+	"scheduler/utils"
+	"sync"
+	"time"
+)
+
+const StickySessionSchedulerName = "StickySessionScheduler"
+
+func init() {
+	Register(StickySessionSchedulerName, "Pin the session key read from a header to the node that served it first, probing F random nodes for new sessions or when that node is saturated or unreachable", []types.SchedulerParameterInfo{
+		{Name: "Header", Type: ParameterTypeString, Default: "X-Session-Key", Description: "header of the function request carrying the session key"},
+		{Name: "TTL", Type: ParameterTypeDuration, Default: "10m", Description: "time after the last job for which a session is kept"},
+		{Name: "F", Type: ParameterTypeUint, Default: 1, Description: "fan-out, number of nodes to probe when the session has no node"},
+		{Name: "T", Type: ParameterTypeUint, Default: 0, Description: "threshold, load from which a node is saturated, 0 for its maximum running functions"},
+		{Name: "Loss", Type: ParameterTypeBool, Default: true, Description: "discard job if queue is full"},
+		{Name: "MaxHops", Type: ParameterTypeUint, Default: 1, Description: "maximum number of hops"},
+		loadComparisonParameterInfo,
+		samplingParameterInfo,
+		maxPingParameterInfo,
+		failurePolicyParameterInfo,
+		maxRetriesParameterInfo,
+	}, newStickySessionScheduler)
+}
+
+type StickySessionScheduler struct {
+	Header         string                         // header carrying the session key
+	TTL            time.Duration                  // time after the last job for which a session is kept
+	F              uint                           // fan-out
+	T              uint                           // threshold
+	Loss           bool                           // discard job if queue is full
+	MaxHops        uint                           // maximum number of hops
+	ProbeOptions   scheduler_service.ProbeOptions // how machines are sampled and their loads compared
+	FailureOptions FailureOptions                 // what to do when the peer cannot execute the job
+	sessions       *sessionTable
+}
+
+// sessionTable holds the node of every session, sessions are known only by the node that receives their jobs
+type sessionTable struct {
+	nodes     map[string]stickySession
+	lastSweep time.Time
+	mutex     sync.Mutex
+}
+
+type stickySession struct {
+	node      string    // ip of the node which serves the session
+	expiresAt time.Time // time at which the session is forgotten
+}
+
+func newStickySessionScheduler(parameters Parameters) (Scheduler, error) {
+	options, err := getProbeOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	failure, err := getFailureOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	if parameters.GetString("Header") == "" {
+		return nil, BadSchedulerParameters{parameter: "Header", reason: "cannot be empty"}
+	}
+	if parameters.GetDuration("TTL") == 0 {
+		return nil, BadSchedulerParameters{parameter: "TTL", reason: "must be greater than 0"}
+	}
+	return &StickySessionScheduler{
+		Header:         parameters.GetString("Header"),
+		TTL:            parameters.GetDuration("TTL"),
+		F:              parameters.GetUint("F"),
+		T:              parameters.GetUint("T"),
+		Loss:           parameters.GetBool("Loss"),
+		MaxHops:        parameters.GetUint("MaxHops"),
+		ProbeOptions:   options,
+		FailureOptions: failure,
+		sessions:       &sessionTable{nodes: map[string]stickySession{}, lastSweep: time.Now()},
+	}, nil
+}
+
+func (s StickySessionScheduler) GetFullName() string {
+	return fmt.Sprintf("%s(%s, %s, %d, %d, %t, %d%s%s)", StickySessionSchedulerName, s.Header, s.TTL, s.F, s.T, s.Loss, s.MaxHops, getProbeOptionsFullName(s.ProbeOptions), getFailureOptionsFullName(s.FailureOptions))
+}
+
+func (s StickySessionScheduler) GetScheduler() *types.SchedulerDescriptor {
+	return &types.SchedulerDescriptor{
+		Name: StickySessionSchedulerName,
+		Parameters: map[string]interface{}{
+			"Header":         s.Header,
+			"TTL":            s.TTL.String(),
+			"F":              s.F,
+			"T":              s.T,
+			"Loss":           s.Loss,
+			"MaxHops":        s.MaxHops,
+			"LoadComparison": s.ProbeOptions.LoadComparison,
+			"Sampling":       s.ProbeOptions.Sampling,
+			"MaxPing":        s.ProbeOptions.MaxPing.String(),
+			"FailurePolicy":  s.FailureOptions.Policy,
+			"MaxRetries":     s.FailureOptions.MaxRetries,
+		},
+		State: map[string]interface{}{
+			"Sessions": s.sessions.size(),
+		},
+	}
+}
+
+// Schedule a service request. This call is blocking until the job has been executed locally or externally.
+func (s StickySessionScheduler) Schedule(req *types.ServiceRequest) (*JobResult, error) {
+	log.Log.Debugf("[R#%d] Scheduling job %s", req.Id, req.ServiceName)
+	currentLoad := memdb.GetTotalRunningFunctions()
+	startedScheduling := time.Now()
+	timingsStart := types.TimingsStart{ArrivedAt: &startedScheduling}
+
+	jobMustExecutedHere := req.External && req.ExternalJobRequest.Hops >= int(s.MaxHops)
+	req.Trace.AddCheck("jobMustExecutedHere", jobMustExecutedHere, "maxHops=%d", s.MaxHops)
+	if jobMustExecutedHere {
+		return executeJobLocally(req, &timingsStart)
+	}
+
+	key := ""
+	if req.Headers != nil {
+		key = req.Headers.Get(s.Header)
+	}
+	if key == "" {
+		return s.scheduleWithoutSession(req, currentLoad, &timingsStart)
+	}
+
+	if node, ok := s.sessions.get(key); ok {
+		result, served, err := s.scheduleOnNode(req, node, currentLoad, &timingsStart)
+		if served {
+			s.sessions.set(key, node, s.TTL)
+			return result, err
+		}
+		log.Log.Debugf("[R#%d] Breaking affinity of session %s with node %s", req.Id, key, node)
+		req.Trace.SetReason("session node %s is saturated or unreachable", node)
+		s.sessions.remove(key)
+	}
+
+	result, err := s.scheduleWithoutSession(req, currentLoad, &timingsStart)
+	if node := getServingMachine(result, err); node != "" {
+		log.Log.Debugf("[R#%d] Pinning session %s to node %s", req.Id, key, node)
+		req.Trace.SetReason("new session pinned to %s", node)
+		s.sessions.set(key, node, s.TTL)
+	}
+	return result, err
+}
+
+// scheduleOnNode executes the job on the node of its session, it returns false if the node is saturated or unreachable
+// and the job has not been executed
+func (s StickySessionScheduler) scheduleOnNode(req *types.ServiceRequest, node string, currentLoad uint, timingsStart *types.TimingsStart) (*JobResult, bool, error) {
+	if node == discovery.Configuration.MachineIp {
+		if isSaturated(scheduler_service.MachineLoad{Running: currentLoad, Max: config.Configuration.GetRunningFunctionMax()}, s.T) {
+			return nil, false, nil
+		}
+		result, err := executeJobLocally(req, timingsStart)
+		return result, true, err
+	}
+
+	if utils.StringInArray(node, getVisitedMachines(req)) {
+		return nil, false, nil
+	}
+
+	// save time
+	startedProbingTime := time.Now()
+	timingsStart.StartedProbingAt = &startedProbingTime
+	load, err := scheduler_service.GetMachineLoad(node, withTrace(s.ProbeOptions, req))
+	// save time
+	endProbingTime := time.Now()
+	timingsStart.EndedProbingAt = &endProbingTime
+
+	if err != nil || isSaturated(*load, s.T) {
+		return nil, false, nil
+	}
+
+	result, err := forwardJob(req, node, timingsStart)
+	if result != nil && err != nil {
+		log.Log.Debugf("[R#%d] Node %s of the session cannot execute the job: %s", req.Id, node, err.Error())
+		return nil, false, nil
+	}
+	if result != nil && !s.ProbeOptions.CachedLoads {
+		result.ProbingMessages = 1
+	}
+	return result, true, err
+}
+
+// scheduleWithoutSession executes the job locally if this node is not saturated, otherwise it forwards it to the least
+// loaded of F random nodes
+func (s StickySessionScheduler) scheduleWithoutSession(req *types.ServiceRequest, currentLoad uint, timingsStart *types.TimingsStart) (*JobResult, error) {
+	if !isSaturated(scheduler_service.MachineLoad{Running: currentLoad, Max: config.Configuration.GetRunningFunctionMax()}, s.T) {
+		return executeJobLocally(req, timingsStart)
+	}
+
+	// save time
+	startedProbingTime := time.Now()
+	timingsStart.StartedProbingAt = &startedProbingTime
+	// get N Random machines and ask them for load and rank the ones less loaded than us
+	candidates, probingMessages, _, err := scheduler_service.GetLessLoadedMachinesOfNRandom(s.F, currentLoad, !s.Loss, withTrace(s.ProbeOptions, req), getVisitedMachines(req))
+	// save time
+	endProbingTime := time.Now()
+	timingsStart.EndedProbingAt = &endProbingTime
+
+	var result *JobResult
+	if err != nil {
+		log.Log.Debugf("[R#%d] Error in retrieving machines %s", req.Id, err.Error())
+		req.Trace.SetReason("no peer can take the job: %s", err.Error())
+		result, err = executeJobLocally(req, timingsStart)
+	} else {
+		result, err = executeJobExternallyWithFallback(req, candidates, timingsStart, s.FailureOptions)
+	}
+
+	if result != nil {
+		result.ProbingMessages = probingMessages
+	}
+	return result, err
+}
+
+// getServingMachine returns the ip of the node that executed the job, empty if the job has not been executed
+func getServingMachine(result *JobResult, err error) string {
+	if err != nil || result == nil || result.Response == nil || result.Response.StatusCode >= 500 {
+		return ""
+	}
+	if !result.ExternalExecution {
+		return discovery.Configuration.MachineIp
+	}
+	// the node that executed the job is the first one of the path, every node it went through appends itself after
+	peers := result.ExternalExecutionInfo.PeersList
+	if len(peers) == 0 {
+		return ""
+	}
+	return peers[0].MachineIp
+}
+
+/*
+ * Sessions
+ */
+
+func (t *sessionTable) get(key string) (string, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	session, ok := t.nodes[key]
+	if !ok || time.Now().After(session.expiresAt) {
+		return "", false
+	}
+	return session.node, true
+}
+
+// set the node of the session, the expired sessions are removed at most once per ttl
+func (t *sessionTable) set(key string, node string, ttl time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	t.nodes[key] = stickySession{node: node, expiresAt: now.Add(ttl)}
+
+	if now.Sub(t.lastSweep) < ttl {
+		return
+	}
+	for k, session := range t.nodes {
+		if now.After(session.expiresAt) {
+			delete(t.nodes, k)
+		}
+	}
+	t.lastSweep = now
+}
+
+func (t *sessionTable) remove(key string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.nodes, key)
+}
+
+func (t *sessionTable) size() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.nodes)
+}
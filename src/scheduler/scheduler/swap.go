@@ -0,0 +1,204 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"scheduler/log"
+	"scheduler/types"
+	"sync"
+	"time"
+)
+
+// runningScheduler wraps a scheduler keeping track of the Schedule calls that are still running on it, in such a way
+// it can be replaced while it is serving requests and we can know when it has been drained
+type runningScheduler struct {
+	Scheduler
+	inFlight  int64
+	retired   bool
+	drained   chan struct{} // closed when the scheduler is retired and has no more in-flight calls
+	drainedAt *time.Time
+	mutex     sync.Mutex
+}
+
+// retirable is implemented by the schedulers publishing their state, which must stop when they are replaced
+type retirable interface {
+	retire()
+}
+
+func newRunningScheduler(s Scheduler) *runningScheduler {
+	return &runningScheduler{
+		Scheduler: s,
+		drained:   make(chan struct{}),
+	}
+}
+
+func (r *runningScheduler) acquire() {
+	r.mutex.Lock()
+	r.inFlight++
+	r.mutex.Unlock()
+}
+
+func (r *runningScheduler) release() {
+	r.mutex.Lock()
+	r.inFlight--
+	if r.retired && r.inFlight == 0 {
+		r.setDrained()
+	}
+	r.mutex.Unlock()
+}
+
+// retire marks the scheduler as no more current, no new calls will be acquired on it
+func (r *runningScheduler) retire() {
+	if s, ok := r.Scheduler.(retirable); ok {
+		s.retire()
+	}
+
+	r.mutex.Lock()
+	r.retired = true
+	if r.inFlight == 0 {
+		r.setDrained()
+	}
+	r.mutex.Unlock()
+}
+
+// setDrained must be called with the mutex held
+func (r *runningScheduler) setDrained() {
+	now := time.Now()
+	r.drainedAt = &now
+	close(r.drained)
+}
+
+func (r *runningScheduler) getInFlight() int64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.inFlight
+}
+
+func (r *runningScheduler) getDrainedAt() *time.Time {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.drainedAt
+}
+
+/*
+ * Swap
+ */
+
+type schedulerSwap struct {
+	old       *runningScheduler
+	newName   string
+	swappedAt time.Time
+	timeout   time.Duration
+}
+
+var lastSwap *schedulerSwap
+var lastSwapMutex sync.Mutex
+
+// SwapScheduler replaces the current scheduler without waiting for the running functions to end. New requests are
+// immediately scheduled by the new scheduler while in-flight Schedule calls complete on the old one. If timeout is not
+// zero the old scheduler is reported as timed out if it is not drained within that time.
+func SwapScheduler(sched *types.SchedulerDescriptor, timeout time.Duration) error {
+	newScheduler, err := newSchedulerFromDescriptor(sched)
+	if err != nil {
+		return err
+	}
+
+	currentSchedulerMutex.Lock()
+	old := currentScheduler
+	currentScheduler = newRunningScheduler(newScheduler)
+	old.retire()
+	currentSchedulerMutex.Unlock()
+
+	swap := &schedulerSwap{
+		old:       old,
+		newName:   newScheduler.GetFullName(),
+		swappedAt: time.Now(),
+		timeout:   timeout,
+	}
+	lastSwapMutex.Lock()
+	lastSwap = swap
+	lastSwapMutex.Unlock()
+
+	log.Log.Infof("Scheduler swapped from '%s' to '%s', %d calls in-flight on the old one", old.GetFullName(), swap.newName, old.getInFlight())
+
+	go waitSwapDrained(swap)
+
+	return nil
+}
+
+// WaitSwapDrained blocks until the old scheduler of the last swap is drained or the timeout expires, it returns true
+// if the old scheduler is drained
+func WaitSwapDrained(timeout time.Duration) bool {
+	lastSwapMutex.Lock()
+	swap := lastSwap
+	lastSwapMutex.Unlock()
+
+	if swap == nil || swap.old.getDrainedAt() != nil {
+		return true
+	}
+
+	select {
+	case <-swap.old.drained:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// GetSwapStatus returns the status of the last scheduler swap, or nil if the scheduler has never been swapped
+func GetSwapStatus() *types.SchedulerSwapStatus {
+	lastSwapMutex.Lock()
+	defer lastSwapMutex.Unlock()
+
+	if lastSwap == nil {
+		return nil
+	}
+
+	drainedAt := lastSwap.old.getDrainedAt()
+	status := types.SchedulerSwapStatus{
+		OldScheduler: lastSwap.old.GetFullName(),
+		NewScheduler: lastSwap.newName,
+		SwappedAt:    lastSwap.swappedAt,
+		InFlight:     lastSwap.old.getInFlight(),
+		Drained:      drainedAt != nil,
+		DrainedAt:    drainedAt,
+	}
+	if lastSwap.timeout > 0 {
+		status.Timeout = lastSwap.timeout.String()
+		status.TimedOut = !status.Drained && time.Since(lastSwap.swappedAt) > lastSwap.timeout
+	}
+
+	return &status
+}
+
+// waitSwapDrained logs the drain of the old scheduler, warning if it takes more than the swap timeout
+func waitSwapDrained(swap *schedulerSwap) {
+	if swap.timeout > 0 {
+		select {
+		case <-swap.old.drained:
+		case <-time.After(swap.timeout):
+			log.Log.Warningf("Old scheduler '%s' not drained after %s, %d calls still in-flight", swap.old.GetFullName(), swap.timeout, swap.old.getInFlight())
+			<-swap.old.drained
+		}
+	} else {
+		<-swap.old.drained
+	}
+
+	log.Log.Infof("Old scheduler '%s' drained in %fs", swap.old.GetFullName(), swap.old.getDrainedAt().Sub(swap.swappedAt).Seconds())
+}
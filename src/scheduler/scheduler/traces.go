@@ -0,0 +1,78 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"scheduler/memdb"
+	"scheduler/types"
+	"sync"
+	"time"
+)
+
+// decisionTracesMax is the number of the last decision traces kept
+const decisionTracesMax = 1000
+
+var decisionTraces []*types.DecisionTrace // ring of the last decision traces
+var decisionTracesNext int                // index of the ring in which the next trace is written
+var decisionTracesMutex sync.Mutex
+
+// GetDecisionTraces returns the last decision traces from the oldest
+func GetDecisionTraces() []*types.DecisionTrace {
+	decisionTracesMutex.Lock()
+	defer decisionTracesMutex.Unlock()
+
+	out := make([]*types.DecisionTrace, 0, len(decisionTraces))
+	if len(decisionTraces) == decisionTracesMax {
+		out = append(out, decisionTraces[decisionTracesNext:]...)
+		out = append(out, decisionTraces[:decisionTracesNext]...)
+	} else {
+		out = append(out, decisionTraces...)
+	}
+	return out
+}
+
+/*
+ * Utils
+ */
+
+func newDecisionTrace(req *types.ServiceRequest, schedulerName string) *types.DecisionTrace {
+	trace := &types.DecisionTrace{}
+	trace.RequestId = req.Id
+	trace.Function = req.ServiceName
+	trace.Scheduler = schedulerName
+	trace.External = req.External
+	trace.Load = memdb.GetTotalRunningFunctions()
+	trace.StartedAt = time.Now()
+	if req.External && req.ExternalJobRequest != nil {
+		trace.Hops = req.ExternalJobRequest.Hops
+	}
+	return trace
+}
+
+func recordDecisionTrace(trace *types.DecisionTrace) {
+	decisionTracesMutex.Lock()
+	defer decisionTracesMutex.Unlock()
+
+	if len(decisionTraces) < decisionTracesMax {
+		decisionTraces = append(decisionTraces, trace)
+	} else {
+		decisionTraces[decisionTracesNext] = trace
+	}
+	decisionTracesNext = (decisionTracesNext + 1) % decisionTracesMax
+}
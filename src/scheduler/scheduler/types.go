@@ -22,6 +22,13 @@ import (
 	"scheduler/types"
 )
 
+// Decisions taken for a job, forward is followed by the ip of the peer like forward:<ip>
+const (
+	DecisionLocal   = "local"
+	DecisionDrop    = "drop"
+	DecisionForward = "forward"
+)
+
 type JobResult struct {
 	Response              *types.APIResponse    `json:"response"`
 	ProbingMessages       uint                  `json:"probing_messages"`
@@ -29,6 +36,9 @@ type JobResult struct {
 	ExternalExecutionInfo ExternalExecutionInfo `json:"external_executed_info"`
 	TimingsStart          *types.TimingsStart   `json:"timings_start"`
 	Timings               *types.Timings        `json:"timings"`
+	ForwardFailures       []string              `json:"forward_failures"` // peers that failed to execute the job, in order
+	LocalFallback         bool                  `json:"local_fallback"`   // executed locally since forwarding failed
+	Hedged                bool                  `json:"hedged"`           // a copy of the job has been sent to a peer
 }
 
 type ExternalExecutionInfo struct {
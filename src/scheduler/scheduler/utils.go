@@ -21,9 +21,13 @@ package scheduler
 import (
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"scheduler/config"
+	"scheduler/discovery"
 	"scheduler/log"
 	"scheduler/memdb"
+	"scheduler/metrics"
 	"scheduler/queue"
 	"scheduler/scheduler_service"
 	"scheduler/types"
@@ -35,12 +39,27 @@ import (
  */
 
 func executeJobExternally(req *types.ServiceRequest, remoteNodeIP string, timingsStart *types.TimingsStart) (*JobResult, error) {
+	result, err := forwardJob(req, remoteNodeIP, timingsStart)
+	if result == nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// forwardJob executes the job at the remote node. The result is nil only if the job cannot be sent, otherwise the
+// returned error tells if the remote node has not been able to execute the job, and in this case the result carries
+// an error response.
+func forwardJob(req *types.ServiceRequest, remoteNodeIP string, timingsStart *types.TimingsStart) (*JobResult, error) {
 	log.Log.Debugf("[R#%d] %s scheduled to be run at %s", req.Id, req.ServiceName, remoteNodeIP)
 	now := time.Now()
 	if timingsStart != nil {
 		timingsStart.ScheduledAt = &now
 	}
 
+	if req.DryRun {
+		return prepareJobResultFromDryRun(timingsStart, remoteNodeIP), nil
+	}
+
 	// prepare everything to send the job externally
 	peerRequest, err := prepareForwardToPeerRequest(req)
 	if err != nil {
@@ -49,13 +68,28 @@ func executeJobExternally(req *types.ServiceRequest, remoteNodeIP string, timing
 	// metrics
 	// metrics.PostJobIsForwarded(req.ServiceName)
 
-	res, err := scheduler_service.ExecuteFunction(remoteNodeIP, peerRequest)
+	res, err := scheduler_service.ExecuteFunction(req.Context, remoteNodeIP, peerRequest)
 	/* This is blocking */
 
-	return prepareJobResultFromExternalExecution(req, res, timingsStart), nil
+	if err != nil && req.Context.Err() != nil {
+		log.Log.Debugf("[R#%d] %s cancelled while forwarded to %s", req.Id, req.ServiceName, remoteNodeIP)
+		req.Trace.SetReason("cancelled by the client while forwarded to %s", remoteNodeIP)
+		metrics.PostJobCancelled(req.ServiceName, metrics.CancelledInForward)
+	}
+
+	return prepareJobResultFromExternalExecution(req, res, timingsStart), err
 }
 
 func executeJobLocally(req *types.ServiceRequest, timingsStart *types.TimingsStart) (*JobResult, error) {
+	return runJobLocally(req, timingsStart, true)
+}
+
+// queueJobLocally is like executeJobLocally but the job waits in the queue if there are no free slots
+func queueJobLocally(req *types.ServiceRequest, timingsStart *types.TimingsStart) (*JobResult, error) {
+	return runJobLocally(req, timingsStart, false)
+}
+
+func runJobLocally(req *types.ServiceRequest, timingsStart *types.TimingsStart, mustHaveFreeSlots bool) (*JobResult, error) {
 	log.Log.Debugf("[R#%d] %s scheduled to be run locally: external=%t", req.Id, req.ServiceName, req.External)
 	now := time.Now()
 	if timingsStart != nil {
@@ -63,8 +97,9 @@ func executeJobLocally(req *types.ServiceRequest, timingsStart *types.TimingsSta
 	}
 
 	freeSlots := memdb.GetFreeSlots()
-	if memdb.GetFreeSlots() <= 0 {
+	if mustHaveFreeSlots && freeSlots <= 0 {
 		log.Log.Debugf("[R#%d] %s cannot be scheduled to be run locally: freeSlots=%d", req.Id, req.ServiceName, freeSlots)
+		req.Trace.SetReason("no free slots for executing locally")
 		return &JobResult{
 			Response:          nil,
 			Timings:           &types.Timings{},
@@ -73,21 +108,62 @@ func executeJobLocally(req *types.ServiceRequest, timingsStart *types.TimingsSta
 		}, JobCannotBeScheduled{}
 	}
 
+	if req.DryRun {
+		return prepareJobResultFromDryRun(timingsStart, ""), nil
+	}
+
 	// If we execute the job locally and request is external, payload is base64encoded and we decode it
 	if req.External {
 		decodedPayload, _ := base64.StdEncoding.DecodeString(string(req.Payload))
 		req.Payload = decodedPayload
 	}
 
-	job, err := queue.EnqueueJob(req)
+	job, err := queue.EnqueueJob(req.Context, req)
 
 	/* This is blocking */
 
+	// the job has been accepted by the queue but it expired or it has been cancelled before completing
+	switch err.(type) {
+	case queue.ErrorExpired, queue.ErrorCancelled:
+		log.Log.Debugf("[R#%d] %s discarded by the queue: %s", req.Id, req.ServiceName, err.Error())
+		req.Trace.SetReason("discarded by the queue: %s", err.Error())
+		return &JobResult{
+			Response:          nil,
+			Timings:           &types.Timings{},
+			TimingsStart:      timingsStart,
+			ExternalExecution: false,
+		}, err
+	}
+	if _, subQueueFull := err.(queue.ErrorSubQueueFull); subQueueFull {
+		log.Log.Debugf("[R#%d] Cannot add job to its sub-queue, job is discarded", req.Id)
+		req.Trace.SetReason("sub-queue is full: %s", err.Error())
+		return &JobResult{
+			Timings:      &types.Timings{},
+			TimingsStart: timingsStart,
+		}, err
+	}
+	if _, queueFull := err.(queue.ErrorFull); queueFull {
+		log.Log.Debugf("[R#%d] Cannot add job to queue, job is discarded", req.Id)
+		req.Trace.SetReason("queue is full: %s", err.Error())
+		return &JobResult{
+			Timings:      &types.Timings{},
+			TimingsStart: timingsStart,
+		}, err
+	}
 	if err != nil {
 		log.Log.Debugf("[R#%d] Cannot add job to queue, job is discarded", req.Id)
+		req.Trace.SetReason("cannot enqueue: %s", err.Error())
 		return nil, err
 	}
 
+	// the job has been stolen by an idle node while waiting in the queue
+	if job.HandedOverTo != "" {
+		log.Log.Debugf("[R#%d] %s has been handed over to %s", req.Id, req.ServiceName, job.HandedOverTo)
+		req.Trace.SetReason("handed over to %s by work stealing", job.HandedOverTo)
+		res := scheduler_service.APIResponse(*job.HandedOverResponse)
+		return prepareJobResultFromExternalExecution(req, &res, timingsStart), nil
+	}
+
 	// Fill the execution time since it is derived from the internal execution
 	executionTime := job.Timings.FaasExecutionTime
 	timings := types.Timings{ExecutionTime: &executionTime}
@@ -166,6 +242,21 @@ func prepareJobResultFromExternalExecution(req *types.ServiceRequest, res *sched
 	return &result
 }
 
+// prepareJobResultFromDryRun returns the result of a job which is not executed, as it would be if it succeeded at the
+// passed node, or locally if the node is empty
+func prepareJobResultFromDryRun(timingsStart *types.TimingsStart, remoteNodeIP string) *JobResult {
+	result := JobResult{
+		Response:     &types.APIResponse{Headers: http.Header{}, StatusCode: 200, Body: []byte{}},
+		TimingsStart: timingsStart,
+		Timings:      &types.Timings{},
+	}
+	if remoteNodeIP != "" {
+		result.ExternalExecution = true
+		result.ExternalExecutionInfo.PeersList = []types.PeersListMember{{MachineIp: remoteNodeIP}}
+	}
+	return &result
+}
+
 /*
  * Utils
  */
@@ -175,16 +266,197 @@ func prepareForwardToPeerRequest(req *types.ServiceRequest) (*types.PeerJobReque
 	peerRequest := types.PeerJobRequest{
 		FunctionName: req.ServiceName,
 		ContentType:  req.ContentType,
+		Key:          req.Key,
+		Priority:     req.Priority,
+		Tenant:       req.Tenant,
 	}
 
 	// If request is external the payload is already in base64
 	if !req.External {
 		// encode payload in base64
 		peerRequest.Payload = base64.StdEncoding.EncodeToString(req.Payload)
-		peerRequest.Hops += 1
+		peerRequest.Hops = 1
 	} else {
 		peerRequest.Payload = string(req.Payload)
-		peerRequest.Hops = 1
+		peerRequest.Hops = req.ExternalJobRequest.Hops + 1
+		peerRequest.PeersList = append(peerRequest.PeersList, req.ExternalJobRequest.PeersList...)
+	}
+	// the peers list carries the path of the job, so that it is not forwarded again to a node it has already visited
+	peerRequest.PeersList = append(peerRequest.PeersList, discovery.GetPeerDescriptor(nil))
+
+	// The deadline is sent as the time left, since clocks of machines are not synchronized
+	if req.Deadline != nil {
+		peerRequest.Deadline = time.Until(*req.Deadline).Seconds()
 	}
 	return &peerRequest, nil
 }
+
+// getPriorityThreshold returns the load from which a job with the passed priority is offloaded, so that low priority
+// jobs leave the node first: they are offloaded from half of the threshold, while high priority ones are offloaded only
+// when there are no free slots left
+func getPriorityThreshold(threshold uint, priority int) uint {
+	if priority < types.PriorityNormal {
+		return threshold / 2
+	}
+	if priority > types.PriorityNormal && threshold < config.Configuration.GetRunningFunctionMax() {
+		return config.Configuration.GetRunningFunctionMax()
+	}
+	return threshold
+}
+
+// isSaturated tells if a machine with the passed load cannot take more jobs, that is when its load reaches the threshold
+// or, if the threshold is 0, its maximum running functions
+func isSaturated(load scheduler_service.MachineLoad, threshold uint) bool {
+	if threshold == 0 {
+		return load.Max > 0 && load.Running >= load.Max
+	}
+	return load.Running >= threshold
+}
+
+// getVisitedMachines returns the ips of the nodes the job has already visited, including this one, which must not be
+// picked for forwarding the job
+func getVisitedMachines(req *types.ServiceRequest) []string {
+	visited := []string{discovery.Configuration.MachineIp}
+	if req.External && req.ExternalJobRequest != nil {
+		for _, peer := range req.ExternalJobRequest.PeersList {
+			visited = append(visited, peer.MachineIp)
+		}
+	}
+	return visited
+}
+
+// applyFunctionDeadline sets the deadline of the function to the request if it has not one already, deadlines are
+// relative to the job arrival
+func applyFunctionDeadline(req *types.ServiceRequest, arrivedAt time.Time) {
+	if req.Deadline != nil {
+		return
+	}
+	if deadline := memdb.GetFunctionDeadline(req.ServiceName); deadline > 0 {
+		requestDeadline := arrivedAt.Add(deadline)
+		req.Deadline = &requestDeadline
+	}
+}
+
+// estimateLocalCompletionTime estimates how long the job would take if executed locally, considering the jobs ahead in
+// the queue and the past execution times. Functions never executed are estimated to take no time.
+func estimateLocalCompletionTime(functionName string) time.Duration {
+	executionTime, ok := memdb.GetFunctionMeanExecutionTime(functionName)
+	if !ok {
+		executionTime, _ = memdb.GetMeanExecutionTime()
+	}
+
+	// if there is a free slot and nobody waiting the job starts immediately, otherwise it has to wait for the jobs
+	// in the queue to be consumed by all the slots
+	waitingTime := 0.0
+	jobsAhead := queue.GetQueueFill()
+	if memdb.GetFreeSlots() <= 0 || jobsAhead > 0 {
+		meanExecutionTime, _ := memdb.GetMeanExecutionTime()
+		slots := config.Configuration.GetRunningFunctionMax()
+		if slots == 0 {
+			slots = 1
+		}
+		waitingTime = float64(jobsAhead+1) / float64(slots) * meanExecutionTime
+	}
+
+	return time.Duration((waitingTime + executionTime) * float64(time.Second))
+}
+
+// Parameters of the schedulers that probe other machines, they are read by getProbeOptionsParameters
+var (
+	loadComparisonParameterInfo = types.SchedulerParameterInfo{
+		Name:        "LoadComparison",
+		Type:        ParameterTypeString,
+		Default:     scheduler_service.LoadComparisonRaw,
+		Description: fmt.Sprintf("how the loads of machines are compared, one of %v", scheduler_service.LoadComparisons),
+	}
+	samplingParameterInfo = types.SchedulerParameterInfo{
+		Name:        "Sampling",
+		Type:        ParameterTypeString,
+		Default:     scheduler_service.SamplingUniform,
+		Description: fmt.Sprintf("how the machines to probe are sampled, one of %v", scheduler_service.Samplings),
+	}
+	maxPingParameterInfo = types.SchedulerParameterInfo{
+		Name:        "MaxPing",
+		Type:        ParameterTypeDuration,
+		Default:     "0s",
+		Description: "machines with a greater ping are never probed, 0s for no limit",
+	}
+)
+
+// ownerParameter is the internal parameter telling schedulers who uses them, for labelling the metrics they publish
+const ownerParameter = "_Owner"
+
+// Owners of the scheduler instances, the ones of functions are like function:<name>
+const (
+	schedulerOwnerGlobal = "global"
+	schedulerOwnerShadow = "shadow"
+)
+
+func getFunctionSchedulerOwner(function string) string {
+	return "function:" + function
+}
+
+// getSchedulerOwner returns who uses the scheduler, the current scheduler if the parameter is not set
+func getSchedulerOwner(parameters Parameters) string {
+	if owner := parameters.GetString(ownerParameter); owner != "" {
+		return owner
+	}
+	return schedulerOwnerGlobal
+}
+
+// cachedLoadsParameter is the internal parameter telling schedulers to use the last known loads instead of probing
+const cachedLoadsParameter = "_CachedLoads"
+
+// getProbeOptionsParameters returns the validated probe options from the parameters, the ones not declared by the
+// scheduler are left to their zero value
+func getProbeOptionsParameters(parameters Parameters) (scheduler_service.ProbeOptions, error) {
+	options := scheduler_service.ProbeOptions{
+		LoadComparison: scheduler_service.LoadComparisonRaw,
+		Sampling:       scheduler_service.SamplingUniform,
+		MaxPing:        parameters.GetDuration(maxPingParameterInfo.Name),
+		CachedLoads:    parameters.GetBool(cachedLoadsParameter),
+	}
+
+	if _, declared := parameters[loadComparisonParameterInfo.Name]; declared {
+		options.LoadComparison = parameters.GetString(loadComparisonParameterInfo.Name)
+		if !scheduler_service.IsValidLoadComparison(options.LoadComparison) {
+			return options, BadSchedulerParameters{
+				parameter: loadComparisonParameterInfo.Name,
+				reason:    fmt.Sprintf("must be one of %v", scheduler_service.LoadComparisons),
+			}
+		}
+	}
+	if _, declared := parameters[samplingParameterInfo.Name]; declared {
+		options.Sampling = parameters.GetString(samplingParameterInfo.Name)
+		if !scheduler_service.IsValidSampling(options.Sampling) {
+			return options, BadSchedulerParameters{
+				parameter: samplingParameterInfo.Name,
+				reason:    fmt.Sprintf("must be one of %v", scheduler_service.Samplings),
+			}
+		}
+	}
+
+	return options, nil
+}
+
+// withTrace returns the probe options that record the probes in the trace of the request
+func withTrace(options scheduler_service.ProbeOptions, req *types.ServiceRequest) scheduler_service.ProbeOptions {
+	options.Trace = req.Trace
+	return options
+}
+
+// getProbeOptionsFullName returns the suffix for the full name of schedulers that probe other machines, options with
+// the default value are omitted
+func getProbeOptionsFullName(options scheduler_service.ProbeOptions) string {
+	out := ""
+	if options.LoadComparison != scheduler_service.LoadComparisonRaw {
+		out += fmt.Sprintf(", %s", options.LoadComparison)
+	}
+	if options.Sampling != scheduler_service.SamplingUniform {
+		out += fmt.Sprintf(", %s", options.Sampling)
+	}
+	if options.MaxPing > 0 {
+		out += fmt.Sprintf(", %dms", options.MaxPing.Milliseconds())
+	}
+	return out
+}
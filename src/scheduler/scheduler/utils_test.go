@@ -0,0 +1,54 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"scheduler/config"
+	"scheduler/types"
+	"testing"
+)
+
+func TestGetPriorityThreshold(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold uint
+		priority  int
+		expected  uint
+	}{
+		{"low offloaded from half", 4, types.PriorityLow, 2},
+		{"lower than low", 4, -5, 2},
+		{"low with threshold of one", 1, types.PriorityLow, 0},
+		{"normal", 2, types.PriorityNormal, 2},
+		{"high offloaded when no slots are left", 2, types.PriorityHigh, 8},
+		{"higher than high", 2, 5, 8},
+		{"high with threshold above the slots", 10, types.PriorityHigh, 10},
+	}
+
+	runningMax := config.Configuration.GetRunningFunctionMax()
+	defer config.Configuration.SetRunningFunctionMax(runningMax)
+	config.Configuration.SetRunningFunctionMax(8)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if threshold := getPriorityThreshold(test.threshold, test.priority); threshold != test.expected {
+				t.Fatalf("expected %d, got %d", test.expected, threshold)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"scheduler/config"
+	"scheduler/log"
+	"scheduler/memdb"
+	"scheduler/queue"
+	"scheduler/scheduler_service"
+	"scheduler/types"
+	"scheduler/utils"
+	"strings"
+	"time"
+)
+
+const WebhookSchedulerName = "WebhookScheduler"
+
+// Fallbacks applied when the decision service does not answer in time or with a valid decision
+const (
+	WebhookFallbackLocal = "local" // execute the job locally
+	WebhookFallbackDrop  = "drop"  // drop the job
+	WebhookFallbackProbe = "probe" // forward the job to the least loaded candidate, if less loaded than us
+)
+
+var WebhookFallbacks = []string{WebhookFallbackLocal, WebhookFallbackDrop, WebhookFallbackProbe}
+
+func init() {
+	Register(WebhookSchedulerName, "Ask an external decision service, over http or a unix socket, whether to execute the job locally, drop it or forward it to a peer", []types.SchedulerParameterInfo{
+		{Name: "Endpoint", Type: ParameterTypeString, Description: "url of the decision service, like http://host:port/path or unix:/path/to.sock"},
+		{Name: "Timeout", Type: ParameterTypeDuration, Default: "200ms", Description: "time within which the decision service must answer"},
+		{Name: "Fallback", Type: ParameterTypeString, Default: WebhookFallbackLocal, Description: fmt.Sprintf("what to do when the decision service fails, one of %v", WebhookFallbacks)},
+		{Name: "F", Type: ParameterTypeUint, Default: 3, Description: "fan-out, number of candidate peers to probe and send to the decision service"},
+		{Name: "MaxHops", Type: ParameterTypeUint, Default: 1, Description: "maximum number of hops"},
+		loadComparisonParameterInfo,
+		samplingParameterInfo,
+		maxPingParameterInfo,
+		failurePolicyParameterInfo,
+		maxRetriesParameterInfo,
+	}, newWebhookScheduler)
+}
+
+type WebhookScheduler struct {
+	Endpoint       string                         // url of the decision service
+	Timeout        time.Duration                  // time within which the decision service must answer
+	Fallback       string                         // what to do when the decision service fails
+	F              uint                           // fan-out
+	MaxHops        uint                           // maximum number of hops
+	ProbeOptions   scheduler_service.ProbeOptions // how machines are sampled and their loads compared
+	FailureOptions FailureOptions                 // what to do when the peer cannot execute the job
+}
+
+func newWebhookScheduler(parameters Parameters) (Scheduler, error) {
+	options, err := getProbeOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	failure, err := getFailureOptionsParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+	s := &WebhookScheduler{
+		Endpoint:       parameters.GetString("Endpoint"),
+		Timeout:        parameters.GetDuration("Timeout"),
+		Fallback:       parameters.GetString("Fallback"),
+		F:              parameters.GetUint("F"),
+		MaxHops:        parameters.GetUint("MaxHops"),
+		ProbeOptions:   options,
+		FailureOptions: failure,
+	}
+
+	if !strings.HasPrefix(s.Endpoint, "http://") && !strings.HasPrefix(s.Endpoint, "https://") && !strings.HasPrefix(s.Endpoint, "unix:") {
+		return nil, BadSchedulerParameters{parameter: "Endpoint", reason: "must be an http url or unix:/path/to.sock"}
+	}
+	if s.Timeout == 0 {
+		return nil, BadSchedulerParameters{parameter: "Timeout", reason: "must be greater than 0"}
+	}
+	if !utils.StringInArray(s.Fallback, WebhookFallbacks) {
+		return nil, BadSchedulerParameters{parameter: "Fallback", reason: fmt.Sprintf("must be one of %v", WebhookFallbacks)}
+	}
+
+	return s, nil
+}
+
+func (s WebhookScheduler) GetFullName() string {
+	return fmt.Sprintf("%s(%s, %s, %s, %d, %d%s%s)", WebhookSchedulerName, s.Endpoint, s.Timeout, s.Fallback, s.F, s.MaxHops, getProbeOptionsFullName(s.ProbeOptions), getFailureOptionsFullName(s.FailureOptions))
+}
+
+func (s WebhookScheduler) GetScheduler() *types.SchedulerDescriptor {
+	return &types.SchedulerDescriptor{
+		Name: WebhookSchedulerName,
+		Parameters: map[string]interface{}{
+			"Endpoint":       s.Endpoint,
+			"Timeout":        s.Timeout.String(),
+			"Fallback":       s.Fallback,
+			"F":              s.F,
+			"MaxHops":        s.MaxHops,
+			"LoadComparison": s.ProbeOptions.LoadComparison,
+			"Sampling":       s.ProbeOptions.Sampling,
+			"MaxPing":        s.ProbeOptions.MaxPing.String(),
+			"FailurePolicy":  s.FailureOptions.Policy,
+			"MaxRetries":     s.FailureOptions.MaxRetries,
+		},
+	}
+}
+
+// Schedule a service request. This call is blocking until the job has been executed locally or externally.
+func (s WebhookScheduler) Schedule(req *types.ServiceRequest) (*JobResult, error) {
+	log.Log.Debugf("[R#%d] Scheduling job %s", req.Id, req.ServiceName)
+	currentLoad := memdb.GetTotalRunningFunctions()
+	startedScheduling := time.Now()
+	timingsStart := types.TimingsStart{ArrivedAt: &startedScheduling}
+
+	jobMustExecutedHere := req.External && req.ExternalJobRequest.Hops >= int(s.MaxHops)
+	req.Trace.AddCheck("jobMustExecutedHere", jobMustExecutedHere, "maxHops=%d", s.MaxHops)
+	if jobMustExecutedHere {
+		return executeJobLocally(req, &timingsStart)
+	}
+
+	// save time
+	startedProbingTime := time.Now()
+	timingsStart.StartedProbingAt = &startedProbingTime
+	visited := getVisitedMachines(req)
+	machines, loads, probingMessages, err := scheduler_service.GetNRandomMachinesLoad(s.F, withTrace(s.ProbeOptions, req), visited)
+	if err != nil {
+		log.Log.Debugf("[R#%d] Cannot get candidates: %s", req.Id, err.Error())
+	}
+	snapshot := s.getSnapshot(req, currentLoad, machines, loads)
+	decision, err := s.askDecision(snapshot, visited)
+	// save time
+	endProbingTime := time.Now()
+	timingsStart.EndedProbingAt = &endProbingTime
+
+	if err != nil {
+		log.Log.Warningf("[R#%d] Decision service failed, applying fallback %s: %s", req.Id, s.Fallback, err.Error())
+		decision = s.getFallbackDecision(currentLoad, machines, loads)
+		req.Trace.SetReason("decision service failed, fallback %s decided %s: %s", s.Fallback, decision, err.Error())
+	} else {
+		req.Trace.SetReason("decision service decided %s", decision)
+	}
+
+	log.Log.Debugf("[R#%d] Decision for %s is %s", req.Id, req.ServiceName, decision)
+
+	var result *JobResult
+	switch {
+	case decision == DecisionDrop:
+		result, err = &JobResult{Timings: &types.Timings{}, TimingsStart: &timingsStart}, JobCannotBeScheduled{"dropped by the decision service"}
+	case strings.HasPrefix(decision, DecisionForward+":"):
+		result, err = executeJobExternallyWithFallback(req, []string{strings.TrimPrefix(decision, DecisionForward+":")}, &timingsStart, s.FailureOptions)
+	default:
+		result, err = executeJobLocally(req, &timingsStart)
+	}
+
+	if result != nil {
+		result.ProbingMessages = probingMessages
+	}
+	return result, err
+}
+
+// getSnapshot returns the state of the node sent to the decision service
+func (s WebhookScheduler) getSnapshot(req *types.ServiceRequest, currentLoad uint, machines []string, loads []*scheduler_service.MachineLoad) *types.DecisionSnapshot {
+	snapshot := &types.DecisionSnapshot{
+		RequestId:  req.Id,
+		Function:   req.ServiceName,
+		External:   req.External,
+		Load:       currentLoad,
+		MaxLoad:    config.Configuration.GetRunningFunctionMax(),
+		QueueFill:  queue.GetQueueFill(),
+		QueueMax:   config.Configuration.GetQueueLengthMax(),
+		Candidates: []types.DecisionCandidate{},
+	}
+	if req.External && req.ExternalJobRequest != nil {
+		snapshot.Hops = req.ExternalJobRequest.Hops
+	}
+	for i, ip := range machines {
+		candidate := types.DecisionCandidate{Ip: ip}
+		if loads[i] != nil {
+			candidate.Load = loads[i].Running
+			candidate.MaxLoad = loads[i].Max
+			candidate.Reachable = true
+		}
+		snapshot.Candidates = append(snapshot.Candidates, candidate)
+	}
+	return snapshot
+}
+
+// askDecision posts the snapshot to the decision service and returns its validated decision, which cannot forward the
+// job to the visited nodes
+func (s WebhookScheduler) askDecision(snapshot *types.DecisionSnapshot, visited []string) (string, error) {
+	body, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := utils.HttpPostJSONWithTimeout(s.Endpoint, string(body), s.Timeout)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	answer, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode >= 300 {
+		return "", fmt.Errorf("decision service replied with status %d", res.StatusCode)
+	}
+
+	decision := strings.TrimSpace(string(answer))
+	if decision == DecisionLocal || decision == DecisionDrop {
+		return decision, nil
+	}
+	if ip := strings.TrimPrefix(decision, DecisionForward+":"); ip != decision && ip != "" {
+		if utils.StringInArray(ip, visited) {
+			return "", fmt.Errorf("decision %s forwards the job to a node it has already visited", decision)
+		}
+		return decision, nil
+	}
+	return "", fmt.Errorf("decision %s is not valid", decision)
+}
+
+// getFallbackDecision returns the decision taken by the fallback
+func (s WebhookScheduler) getFallbackDecision(currentLoad uint, machines []string, loads []*scheduler_service.MachineLoad) string {
+	switch s.Fallback {
+	case WebhookFallbackDrop:
+		return DecisionDrop
+	case WebhookFallbackProbe:
+		ourLoad := scheduler_service.MachineLoad{Running: currentLoad, Max: config.Configuration.GetRunningFunctionMax()}.Score(s.ProbeOptions.LoadComparison)
+		best := -1
+		bestLoad := ourLoad
+		for i := range machines {
+			if loads[i] == nil {
+				continue
+			}
+			if load := loads[i].Score(s.ProbeOptions.LoadComparison); load < bestLoad {
+				best = i
+				bestLoad = load
+			}
+		}
+		if best >= 0 {
+			return DecisionForward + ":" + machines[best]
+		}
+	}
+	return DecisionLocal
+}
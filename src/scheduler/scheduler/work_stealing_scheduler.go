@@ -0,0 +1,169 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package scheduler
+
+import (
+	"fmt"
+	"scheduler/discovery"
+	"scheduler/log"
+	"scheduler/memdb"
+	"scheduler/queue"
+	"scheduler/scheduler_service"
+	"scheduler/types"
+	"time"
+)
+
+const WorkStealingSchedulerName = "WorkStealingScheduler"
+
+// workStealingIdleInterval is how often the stealer checks if the current scheduler is a WorkStealingScheduler
+const workStealingIdleInterval = 1 * time.Second
+
+func init() {
+	Register(WorkStealingSchedulerName, "Queue every job locally and, when there are at least MinFreeSlots free slots, ask F random nodes for their queued jobs", []types.SchedulerParameterInfo{
+		{Name: "F", Type: ParameterTypeUint, Default: 1, Description: "number of nodes asked for jobs at every round"},
+		{Name: "MinFreeSlots", Type: ParameterTypeUint, Default: 1, Description: "free slots from which jobs are asked to other nodes"},
+		{Name: "Interval", Type: ParameterTypeDuration, Default: "100ms", Description: "time between two rounds of asking jobs"},
+	}, newWorkStealingScheduler)
+}
+
+// WorkStealingScheduler is receiver-initiated: jobs are never forwarded by the node that receives them, but they wait in
+// its queue where idle nodes can steal them. Stolen jobs are executed by the idle node as a forwarded job and the
+// response goes back to the node that received the job, which replies to the client.
+type WorkStealingScheduler struct {
+	F            uint          // number of nodes asked for jobs at every round
+	MinFreeSlots uint          // free slots from which jobs are asked to other nodes
+	Interval     time.Duration // time between two rounds of asking jobs
+}
+
+func newWorkStealingScheduler(parameters Parameters) (Scheduler, error) {
+	interval := parameters.GetDuration("Interval")
+	if interval <= 0 {
+		return nil, BadSchedulerParameters{parameter: "Interval", reason: "must be positive"}
+	}
+	return &WorkStealingScheduler{
+		F:            parameters.GetUint("F"),
+		MinFreeSlots: parameters.GetUint("MinFreeSlots"),
+		Interval:     interval,
+	}, nil
+}
+
+func (s WorkStealingScheduler) GetFullName() string {
+	return fmt.Sprintf("%s(%d, %d, %dms)", WorkStealingSchedulerName, s.F, s.MinFreeSlots, s.Interval.Milliseconds())
+}
+
+func (s WorkStealingScheduler) GetScheduler() *types.SchedulerDescriptor {
+	return &types.SchedulerDescriptor{
+		Name: WorkStealingSchedulerName,
+		Parameters: map[string]interface{}{
+			"F":            s.F,
+			"MinFreeSlots": s.MinFreeSlots,
+			"Interval":     fmt.Sprintf("%dms", s.Interval.Milliseconds()),
+		},
+	}
+}
+
+// Schedule a service request. This call is blocking until the job has been executed locally or by the node that stole it.
+func (s WorkStealingScheduler) Schedule(req *types.ServiceRequest) (*JobResult, error) {
+	log.Log.Debugf("[R#%d] Scheduling job %s", req.Id, req.ServiceName)
+	now := time.Now()
+	timingsStart := types.TimingsStart{ArrivedAt: &now}
+
+	return queueJobLocally(req, &timingsStart)
+}
+
+// steal asks the jobs to other nodes, if we have enough free slots and nothing waiting in our queue
+func (s WorkStealingScheduler) steal() {
+	freeSlots := memdb.GetFreeSlots()
+	if freeSlots < int(s.MinFreeSlots) || freeSlots <= 0 || queue.GetQueueFill() > 0 {
+		return
+	}
+
+	machines, err := discovery.GetNRandomMachines(s.F, []string{discovery.Configuration.MachineIp})
+	if err != nil {
+		log.Log.Debugf("Cannot get machines to steal from: %s", err.Error())
+		return
+	}
+
+	for _, machine := range machines {
+		if freeSlots <= 0 {
+			break
+		}
+		stolen, err := scheduler_service.StealJobs(machine, &types.PeerStealRequest{
+			MachineIp: discovery.Configuration.MachineIp,
+			Slots:     uint(freeSlots),
+		})
+		if err != nil {
+			continue
+		}
+		if stolen > 0 {
+			log.Log.Debugf("Stolen %d jobs from %s", stolen, machine)
+		}
+		freeSlots -= int(stolen)
+	}
+}
+
+// startWorkStealing runs the rounds of asking jobs to other nodes while the current scheduler is a WorkStealingScheduler
+func startWorkStealing() {
+	go func() {
+		for {
+			interval := workStealingIdleInterval
+			if s, ok := getCurrentScheduler().Scheduler.(*WorkStealingScheduler); ok {
+				s.steal()
+				interval = s.Interval
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+/*
+ * Hand over
+ */
+
+// HandOverJobs gives up to n jobs waiting in our queue to the idle node, which executes them as forwarded jobs. It
+// returns the number of jobs handed over, which are sent in background.
+func HandOverJobs(nodeIP string, n uint) uint {
+	jobs := queue.StealJobs(int(n))
+	for _, job := range jobs {
+		go handOverJob(job, nodeIP)
+	}
+	return uint(len(jobs))
+}
+
+// handOverJob forwards the stolen job to the idle node and gives the response to the one waiting for the job, if the
+// node cannot execute the job it goes back in our queue
+func handOverJob(job *queue.QueuedJob, nodeIP string) {
+	log.Log.Debugf("[R#%d] Handing over %s to %s", job.Request.Id, job.Request.ServiceName, nodeIP)
+
+	peerRequest, err := prepareForwardToPeerRequest(job.Request)
+	if err != nil {
+		queue.RequeueJob(job)
+		return
+	}
+
+	res, err := scheduler_service.ExecuteFunction(job.Request.Context, nodeIP, peerRequest)
+	if err != nil || res == nil {
+		log.Log.Debugf("[R#%d] Cannot hand over %s to %s, requeued", job.Request.Id, job.Request.ServiceName, nodeIP)
+		queue.RequeueJob(job)
+		return
+	}
+
+	response := types.APIResponse(*res)
+	queue.CompleteHandedOverJob(job, nodeIP, &response)
+}
@@ -38,3 +38,7 @@ func GetMonitoringLoadUrl(host string) string {
 func GetPeerFunctionUrl(host string, functionName string) string {
 	return fmt.Sprintf("%s/peer/function/%s", GetApiUrl(host), functionName)
 }
+
+func GetPeerStealUrl(host string) string {
+	return fmt.Sprintf("%s/peer/steal", GetApiUrl(host))
+}
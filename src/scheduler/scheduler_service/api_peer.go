@@ -19,6 +19,7 @@
 package scheduler_service
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"scheduler/log"
@@ -26,7 +27,7 @@ import (
 	"scheduler/utils"
 )
 
-func peerFunctionApiCall(host string, request *types.PeerJobRequest) (*APIResponse, error) {
+func peerFunctionApiCall(ctx context.Context, host string, request *types.PeerJobRequest) (*APIResponse, error) {
 	payload, err := json.Marshal(request)
 	if err != nil {
 		log.Log.Errorf("Cannot encode to json payload")
@@ -36,7 +37,7 @@ func peerFunctionApiCall(host string, request *types.PeerJobRequest) (*APIRespon
 	log.Log.Debugf("Calling POST to %s", GetPeerFunctionUrl(host, request.FunctionName))
 	// log.Log.Debugf("len(payload)=%d len(peers)=%d content_type=%s", len(payload), len(request.PeersList), request.ContentType)
 
-	res, err := utils.HttpMachinePostJSON(GetPeerFunctionUrl(host, request.FunctionName), string(payload))
+	res, err := utils.HttpMachinePostJSONWithContext(ctx, GetPeerFunctionUrl(host, request.FunctionName), string(payload))
 	if err != nil {
 		log.Log.Errorf("Cannot create POST request to %s: %s", GetPeerFunctionUrl(host, request.FunctionName), err.Error())
 		return nil, err
@@ -52,3 +53,32 @@ func peerFunctionApiCall(host string, request *types.PeerJobRequest) (*APIRespon
 
 	return &response, err
 }
+
+func peerStealApiCall(host string, request *types.PeerStealRequest) (*types.PeerStealResponse, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		log.Log.Errorf("Cannot encode to json payload")
+		return nil, err
+	}
+
+	res, err := utils.HttpMachinePostJSON(GetPeerStealUrl(host), string(payload))
+	if err != nil {
+		log.Log.Errorf("Cannot create POST request to %s: %s", GetPeerStealUrl(host), err.Error())
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != 200 {
+		return nil, ErrorPeerReply{host: host, statusCode: res.StatusCode}
+	}
+
+	var response types.PeerStealResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		log.Log.Errorf("Cannot decode steal response from %s: %s", host, err.Error())
+		return nil, err
+	}
+
+	return &response, nil
+}
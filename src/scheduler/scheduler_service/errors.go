@@ -18,6 +18,8 @@
 
 package scheduler_service
 
+import "fmt"
+
 type NoLessLoadedMachine struct {
 	Reason string
 }
@@ -25,3 +27,20 @@ type NoLessLoadedMachine struct {
 func (n NoLessLoadedMachine) Error() string {
 	return n.Reason
 }
+
+type ErrorNoCachedLoad struct {
+	host string
+}
+
+func (e ErrorNoCachedLoad) Error() string {
+	return fmt.Sprintf("Load of machine %s is not known", e.host)
+}
+
+type ErrorPeerReply struct {
+	host       string
+	statusCode int
+}
+
+func (e ErrorPeerReply) Error() string {
+	return fmt.Sprintf("Machine %s replied with status code %d", e.host, e.statusCode)
+}
@@ -19,32 +19,78 @@
 package scheduler_service
 
 import (
+	"context"
 	"scheduler/api/api_monitoring"
 	"scheduler/log"
 	"scheduler/types"
 	"strconv"
+	"sync"
 )
 
-// GetLoad allows to get the load of another machine, from a machine
-func GetLoad(host string) (int, *APIResponse, error) {
+// last load probed from every machine, used for evaluating schedulers without sending probes
+var lastLoads = map[string]MachineLoad{}
+var lastLoadsMutex sync.RWMutex
+
+// GetLoad allows to get the load of another machine, from a machine. If the machine does not tell its maximum load, Max
+// is left to 0.
+func GetLoad(host string) (*MachineLoad, *APIResponse, error) {
 	res, err := monitoringLoadGetApiCall(host)
 	if err != nil {
 		log.Log.Debugf("Cannot get load from scheduler service: %s", err.Error())
-		return -1, res, err
+		return nil, res, err
 	}
 
-	load, err := strconv.Atoi(res.Headers.Get(api_monitoring.ApiMonitoringLoadHeaderKey))
+	load, err := strconv.ParseUint(res.Headers.Get(api_monitoring.ApiMonitoringLoadHeaderKey), 10, 32)
 	if err != nil {
 		log.Log.Debugf("Cannot get load from scheduler service: %s", err.Error())
-		return -1, res, err
+		return nil, res, err
 	}
 
-	return load, nil, nil
+	maxLoad, err := strconv.ParseUint(res.Headers.Get(api_monitoring.ApiMonitoringMaxLoadHeaderKey), 10, 32)
+	if err != nil {
+		log.Log.Debugf("Cannot get max load from scheduler service: %s", err.Error())
+		maxLoad = 0
+	}
+
+	machineLoad := MachineLoad{Running: uint(load), Max: uint(maxLoad)}
+	lastLoadsMutex.Lock()
+	lastLoads[host] = machineLoad
+	lastLoadsMutex.Unlock()
+
+	return &machineLoad, nil, nil
 }
 
-// ExecuteFunction allows to request another machine to execute a function
-func ExecuteFunction(host string, request *types.PeerJobRequest) (*APIResponse, error) {
-	res, err := peerFunctionApiCall(host, request)
+// GetMachineLoad returns the load of another machine, that is the last known one if options.CachedLoads is set. The
+// load is recorded in options.Trace.
+func GetMachineLoad(host string, options ProbeOptions) (*MachineLoad, error) {
+	load, err := getMachineLoad(host, options.CachedLoads)
+	if err != nil {
+		options.Trace.AddProbe(host, 0, 0, false)
+		return nil, err
+	}
+	options.Trace.AddProbe(host, load.Running, load.Max, true)
+	return load, nil
+}
+
+func getMachineLoad(host string, cached bool) (*MachineLoad, error) {
+	if !cached {
+		load, _, err := GetLoad(host)
+		return load, err
+	}
+
+	lastLoadsMutex.RLock()
+	load, ok := lastLoads[host]
+	lastLoadsMutex.RUnlock()
+	if !ok {
+		return nil, ErrorNoCachedLoad{host}
+	}
+	return &load, nil
+}
+
+// ExecuteFunction allows to request another machine to execute a function, the request is aborted when the context is
+// done
+func ExecuteFunction(ctx context.Context, host string, request *types.PeerJobRequest) (*APIResponse, error) {
+	res, err := peerFunctionApiCall(ctx, host, request)
 	if err != nil {
 		log.Log.Debugf("Cannot execute function on machine %s: %s", err.Error())
 		return res, err
@@ -52,3 +98,15 @@ func ExecuteFunction(host string, request *types.PeerJobRequest) (*APIResponse,
 
 	return res, nil
 }
+
+// StealJobs asks another machine to hand over the jobs waiting in its queue, up to the slots in the request. The jobs are
+// sent back as peer function requests, the reply only tells how many they are.
+func StealJobs(host string, request *types.PeerStealRequest) (uint, error) {
+	res, err := peerStealApiCall(host, request)
+	if err != nil {
+		log.Log.Debugf("Cannot steal jobs from machine %s: %s", host, err.Error())
+		return 0, err
+	}
+
+	return res.Jobs, nil
+}
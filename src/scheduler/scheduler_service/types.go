@@ -18,10 +18,85 @@
 
 package scheduler_service
 
-import "net/http"
+import (
+	"math"
+	"net/http"
+	"scheduler/types"
+	"time"
+)
 
 type APIResponse struct {
 	Headers    http.Header
 	Body       []byte
 	StatusCode int
 }
+
+// Ways in which the loads of two machines can be compared
+const (
+	LoadComparisonRaw         = "raw"         // number of running functions
+	LoadComparisonUtilization = "utilization" // running functions over the maximum running functions
+	LoadComparisonFreeSlots   = "free_slots"  // maximum running functions minus running functions
+)
+
+// LoadComparisons are all the available ways of comparing loads
+var LoadComparisons = []string{LoadComparisonRaw, LoadComparisonUtilization, LoadComparisonFreeSlots}
+
+// Ways in which the machines to probe can be sampled
+const (
+	SamplingUniform = "uniform" // all machines have the same probability
+	SamplingPing    = "ping"    // machines are weighted by the inverse of their ping
+)
+
+// Samplings are all the available ways of sampling machines
+var Samplings = []string{SamplingUniform, SamplingPing}
+
+// ProbeOptions tell how the machines to probe are sampled and how their loads are compared
+type ProbeOptions struct {
+	LoadComparison string               // see LoadComparisons
+	Sampling       string               // see Samplings
+	MaxPing        time.Duration        // machines with a greater ping are never probed, 0 for no limit
+	CachedLoads    bool                 // use the last known load of the machines instead of probing them
+	Trace          *types.DecisionTrace // when set, the probed machines and their loads are recorded in it
+}
+
+// MachineLoad is the load of a machine as it is told by the monitoring api
+type MachineLoad struct {
+	Running uint // number of running functions
+	Max     uint // maximum number of running functions, 0 if not known
+}
+
+// Score returns a value of the load suitable for comparing machines with the passed comparison: the lower the score the
+// less loaded the machine
+func (l MachineLoad) Score(comparison string) float64 {
+	switch comparison {
+	case LoadComparisonUtilization:
+		if l.Max == 0 {
+			return math.Inf(1)
+		}
+		return float64(l.Running) / float64(l.Max)
+	case LoadComparisonFreeSlots:
+		return -(float64(l.Max) - float64(l.Running))
+	default:
+		return float64(l.Running)
+	}
+}
+
+// IsValidLoadComparison checks if the passed load comparison exists
+func IsValidLoadComparison(comparison string) bool {
+	for _, c := range LoadComparisons {
+		if c == comparison {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidSampling checks if the passed sampling exists
+func IsValidSampling(sampling string) bool {
+	for _, s := range Samplings {
+		if s == sampling {
+			return true
+		}
+	}
+	return false
+}
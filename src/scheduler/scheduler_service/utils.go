@@ -19,87 +19,59 @@
 package scheduler_service
 
 import (
+	"math"
+	"scheduler/config"
 	"scheduler/discovery"
 	"scheduler/log"
 	"scheduler/utils"
+	"sort"
 	"sync"
 	"time"
 )
 
 // getLeastLoadedMachine retrieves the least loaded machine from an array of ips, if all machines are full loaded,
-// the least queue is returned, and if there is no less loaded queue than us, an error is returned. This function returns
-// (ip, mean_probing_time, errors)
-func GetLeastLoadedMachineOfNRandom(n uint, currentLoad uint, checkQueues bool) (string, float64, error) {
+// the least queue is returned, and if there is no less loaded queue than us, an error is returned. Machines are sampled
+// and their loads compared as told by the passed options, the excluded ips are never sampled. This function returns
+// (ip, probing_messages, mean_probing_time, errors)
+func GetLeastLoadedMachineOfNRandom(n uint, currentLoad uint, checkQueues bool, options ProbeOptions, exclude []string) (string, uint, float64, error) {
+	machines, probingMessages, probingTime, err := GetLessLoadedMachinesOfNRandom(n, currentLoad, checkQueues, options, exclude)
+	if err != nil {
+		return "", probingMessages, probingTime, err
+	}
+	return machines[0], probingMessages, probingTime, nil
+}
+
+// GetLessLoadedMachinesOfNRandom is like GetLeastLoadedMachineOfNRandom but it returns all the probed machines which can
+// take the job: the first is the one GetLeastLoadedMachineOfNRandom would pick and the others follow by increasing load,
+// so that they can be tried in order if the first one fails. The probing messages are the probes actually sent, which are
+// less than n if there are not enough machines that are not excluded and none if the loads are cached. This function
+// returns (ips, probing_messages, mean_probing_time, errors)
+func GetLessLoadedMachinesOfNRandom(n uint, currentLoad uint, checkQueues bool, options ProbeOptions, exclude []string) ([]string, uint, float64, error) {
 	startProbingTime := time.Now()
 
 	// get n random machines from discovery
-	machines, err := discovery.GetNRandomMachines(n)
+	machines, err := getNRandomMachines(n, options, exclude)
 	if err != nil {
 		log.Log.Errorf("Cannot get random machines from discovery service")
-		return "", 0.0, err
+		return nil, 0, 0.0, err
 	}
 
 	log.Log.Debugf("len(machines)=%d", len(machines))
-	loads := make([]uint, n) // list of loads
-	// queues := make([]float64, n) // percentage of queue fill
-	probeErr := make([]bool, n) // list of probe errors
-
-	wg := sync.WaitGroup{}
-	// get and compute the load of all the available machines in parallel
-	for i, ip := range machines {
-		wg.Add(1)
-
-		ip := ip
-		i := i
-		go func() {
-			machineLoad, _, err := GetLoad(ip)
-			if err != nil {
-				log.Log.Errorf("Cannot get load from machine %s", ip)
-				probeErr[i] = true
-				wg.Done()
-				return
-			}
-
-			// load := machineLoad.FunctionsRunningMax - machineLoad.FunctionsRunning
-			/*
-				freeQueue := float64(machineLoad.QueueFill) / float64(machineLoad.QueueLengthMax)
-				if freeQueue == math.NaN() {
-					log.Log.Debugf("Queue fill value is NaN from machine %s", ip)
-					probeErr[i] = true
-					wg.Done()
-					return
-				}
-			*/
-			loads[i] = uint(machineLoad)
-			// queues[i] = 0
-			probeErr[i] = false
-			wg.Done()
-		}()
-
-	}
-	wg.Wait()
-
-	log.Log.Debugf("loads=%s", loads)
-	log.Log.Debugf("probeErrs=%s", probeErr)
+	loads, probingMessages, probeErrors := probeMachinesLoad(machines, options)
+	ourLoad := getCurrentMachineLoad(currentLoad).Score(options.LoadComparison)
 
 	probingTime := time.Since(startProbingTime).Seconds()
 
 	// Check if we have enough correct loads
-	probeErrors := 0
-	for i := 0; i < int(n); i++ {
-		if probeErr[i] {
-			probeErrors += 1
-		}
-	}
-	if probeErrors == int(n) {
-		return "", probingTime, NoLessLoadedMachine{"all probe errors"}
+	if probeErrors == len(machines) {
+		return nil, probingMessages, probingTime, NoLessLoadedMachine{"all probe errors"}
 	}
 
 	// pick the less loaded
-	minLoad, _ := utils.MinOfArrayUint(loads)
+	minLoad, _ := utils.MinOfArrayFloat(loads)
 	// if no other machine has free slots, see which queue is less loaded
-	if minLoad >= currentLoad {
-		return "", probingTime, NoLessLoadedMachine{"minLoad >= currentLoad"}
+	if minLoad >= ourLoad {
+		return nil, probingMessages, probingTime, NoLessLoadedMachine{"minLoad >= currentLoad"}
 		/*
 			==> Queues are no more supported! ==>
 
@@ -118,7 +90,188 @@ func GetLeastLoadedMachineOfNRandom(n uint, currentLoad uint, checkQueues bool)
 		*/
 	} else {
 		// pick one random machine among the less loaded than us
-		valuableMachinesIds := utils.LoadsBelowSpecificLoad(loads, currentLoad)
-		return machines[valuableMachinesIds[utils.GetRandomInteger(len(valuableMachinesIds))]], probingTime, nil
+		valuableMachinesIds := utils.LoadsBelowSpecificLoadFloat(loads, ourLoad)
+		picked := valuableMachinesIds[utils.GetRandomInteger(len(valuableMachinesIds))]
+		return rankMachines(machines, loads, valuableMachinesIds, picked), probingMessages, probingTime, nil
+	}
+}
+
+// GetLeastLoadedMachineOfAll probes all the known machines and returns the one with the lowest load, only if its load is
+// strictly below the current load, ties are broken at random. Machines with a ping above options.MaxPing are skipped and
+// loads are compared with options.LoadComparison, the excluded ips are never probed. This function returns (ip,
+// probing_messages, probing_time, errors)
+func GetLeastLoadedMachineOfAll(currentLoad uint, checkQueues bool, options ProbeOptions, exclude []string) (string, uint, float64, error) {
+	machines, probingMessages, probingTime, err := GetLessLoadedMachinesOfAll(currentLoad, checkQueues, options, exclude)
+	if err != nil {
+		return "", probingMessages, probingTime, err
+	}
+	return machines[0], probingMessages, probingTime, nil
+}
+
+// GetLessLoadedMachinesOfAll is like GetLeastLoadedMachineOfAll but it returns all the machines with a load strictly
+// below the current load: the first is the one GetLeastLoadedMachineOfAll would pick and the others follow by increasing
+// load. This function returns (ips, probing_messages, probing_time, errors)
+func GetLessLoadedMachinesOfAll(currentLoad uint, checkQueues bool, options ProbeOptions, exclude []string) ([]string, uint, float64, error) {
+	startProbingTime := time.Now()
+
+	machines, err := getAllMachines(options, exclude)
+	if err != nil {
+		log.Log.Errorf("Cannot get machines from discovery service")
+		return nil, 0, 0.0, err
+	}
+	if len(machines) == 0 {
+		return nil, 0, 0.0, NoLessLoadedMachine{"no machines known"}
+	}
+
+	log.Log.Debugf("len(machines)=%d", len(machines))
+	loads, probingMessages, probeErrors := probeMachinesLoad(machines, options)
+	ourLoad := getCurrentMachineLoad(currentLoad).Score(options.LoadComparison)
+
+	probingTime := time.Since(startProbingTime).Seconds()
+
+	if probeErrors == len(machines) {
+		return nil, probingMessages, probingTime, NoLessLoadedMachine{"all probe errors"}
+	}
+
+	minLoad, _ := utils.MinOfArrayFloat(loads)
+	if minLoad >= ourLoad {
+		return nil, probingMessages, probingTime, NoLessLoadedMachine{"minLoad >= currentLoad"}
+	}
+
+	// pick one random machine among the least loaded ones
+	leastLoadedMachinesIds := utils.LoadsBelowSpecificLoadFloat(loads, minLoad)
+	picked := leastLoadedMachinesIds[utils.GetRandomInteger(len(leastLoadedMachinesIds))]
+
+	var lessLoadedMachinesIds []uint
+	for i, load := range loads {
+		if load < ourLoad {
+			lessLoadedMachinesIds = append(lessLoadedMachinesIds, uint(i))
+		}
+	}
+	return rankMachines(machines, loads, lessLoadedMachinesIds, picked), probingMessages, probingTime, nil
+}
+
+// GetNRandomMachinesLoad samples n machines as told by the options, excluding the passed ips, and returns them with their
+// loads and the number of probes sent, the load of a machine that cannot be probed is nil
+func GetNRandomMachinesLoad(n uint, options ProbeOptions, exclude []string) ([]string, []*MachineLoad, uint, error) {
+	machines, err := getNRandomMachines(n, options, exclude)
+	if err != nil {
+		log.Log.Errorf("Cannot get random machines from discovery service")
+		return nil, nil, 0, err
+	}
+	loads, probes := probeMachines(machines, options)
+	return machines, loads, probes, nil
+}
+
+// probeMachinesLoad gets in parallel the load of all the passed machines, as score of options.LoadComparison.
+// Machines that cannot be probed have an infinite load, so that they are never picked. It returns the loads, the number
+// of probes sent and the number of probe errors.
+func probeMachinesLoad(machines []string, options ProbeOptions) ([]float64, uint, int) {
+	machineLoads, probes := probeMachines(machines, options)
+	loads := make([]float64, len(machines)) // list of loads
+	probeErr := make([]bool, len(machines)) // list of probe errors
+
+	for i := range machines {
+		if machineLoads[i] == nil {
+			probeErr[i] = true
+			continue
+		}
+		loads[i] = machineLoads[i].Score(options.LoadComparison)
+	}
+
+	log.Log.Debugf("loads=%v", loads)
+	log.Log.Debugf("probeErrs=%v", probeErr)
+
+	probeErrors := 0
+	for i := range machines {
+		if probeErr[i] {
+			loads[i] = math.Inf(1)
+			probeErrors += 1
+		}
+	}
+
+	return loads, probes, probeErrors
+}
+
+// probeMachines gets in parallel the load of all the passed machines, nil for the ones that cannot be probed. It also
+// returns the number of probes sent, that is none when the last known loads are used.
+func probeMachines(machines []string, options ProbeOptions) ([]*MachineLoad, uint) {
+	loads := make([]*MachineLoad, len(machines))
+
+	wg := sync.WaitGroup{}
+	// get the load of all the available machines in parallel
+	for i, ip := range machines {
+		wg.Add(1)
+
+		ip := ip
+		i := i
+		go func() {
+			machineLoad, err := GetMachineLoad(ip, options)
+			if err != nil {
+				log.Log.Errorf("Cannot get load from machine %s", ip)
+				wg.Done()
+				return
+			}
+
+			loads[i] = machineLoad
+			wg.Done()
+		}()
+
+	}
+	wg.Wait()
+
+	if options.CachedLoads {
+		return loads, 0
+	}
+	return loads, uint(len(machines))
+}
+
+// getCurrentMachineLoad returns the load of this machine given its number of running functions
+func getCurrentMachineLoad(currentLoad uint) MachineLoad {
+	return MachineLoad{Running: currentLoad, Max: config.Configuration.GetRunningFunctionMax()}
+}
+
+// getNRandomMachines samples n machines from discovery as told by the options, excluding the passed ips
+func getNRandomMachines(n uint, options ProbeOptions, exclude []string) ([]string, error) {
+	if options.Sampling != SamplingPing && options.MaxPing == 0 {
+		return discovery.GetNRandomMachines(n, exclude)
+	}
+	return discovery.GetNRandomMachinesByPing(n, options.MaxPing, options.Sampling == SamplingPing, exclude)
+}
+
+// getAllMachines returns all the machines from discovery with a ping within options.MaxPing, excluding the passed ips
+func getAllMachines(options ProbeOptions, exclude []string) ([]string, error) {
+	machines, err := discovery.GetMachines()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, machine := range machines {
+		if options.MaxPing > 0 && machine.GetPing() > options.MaxPing {
+			continue
+		}
+		if utils.StringInArray(machine.IP, exclude) {
+			continue
+		}
+		out = append(out, machine.IP)
+	}
+	return out, nil
+}
+
+// rankMachines returns the ips of the candidates machines, the picked one first and then the others by increasing load
+func rankMachines(machines []string, loads []float64, candidates []uint, picked uint) []string {
+	var others []uint
+	for _, i := range candidates {
+		if i != picked {
+			others = append(others, i)
+		}
+	}
+	sort.SliceStable(others, func(a, b int) bool { return loads[others[a]] < loads[others[b]] })
+
+	out := []string{machines[picked]}
+	for _, i := range others {
+		out = append(out, machines[i])
 	}
+	return out
 }
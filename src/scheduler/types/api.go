@@ -38,11 +38,15 @@ type Load struct {
 
 type PeerJobRequest struct {
 	// Function    faas.Function     `json:"function"`     // the function that we want to execute
-	FunctionName string            `json:"function_name"` // the function name to execute
-	Hops         int               `json:"hops"`          // number of times the job is forwarded
-	PeersList    []PeersListMember `json:"peers_list"`    // list of peers that handled the job
-	Payload      string            `json:"payload"`       // the payload of the request in base64 string
-	ContentType  string            `json:"content_type"`  // the mime type of the payload
+	FunctionName string            `json:"function_name"`      // the function name to execute
+	Hops         int               `json:"hops"`               // number of times the job is forwarded
+	PeersList    []PeersListMember `json:"peers_list"`         // list of peers that handled the job
+	Payload      string            `json:"payload"`            // the payload of the request in base64 string
+	ContentType  string            `json:"content_type"`       // the mime type of the payload
+	Deadline     float64           `json:"deadline,omitempty"` // seconds left to complete the job, 0 if no deadline
+	Key          string            `json:"key,omitempty"`      // key of the request for scheduling affinity
+	Priority     int               `json:"priority,omitempty"` // priority of the job, 0 is the normal one
+	Tenant       string            `json:"tenant,omitempty"`   // tenant the job belongs to
 }
 
 type PeerJobResponse struct {
@@ -51,6 +55,15 @@ type PeerJobResponse struct {
 	StatusCode int               `json:"status_code"` // job response status code
 }
 
+type PeerStealRequest struct {
+	MachineIp string `json:"machine_ip"` // ip of the idle node that asks for jobs
+	Slots     uint   `json:"slots"`      // maximum number of jobs the idle node can take
+}
+
+type PeerStealResponse struct {
+	Jobs uint `json:"jobs"` // number of jobs handed over to the idle node
+}
+
 type PeersListMember struct {
 	MachineId string  `json:"machine_id"`
 	MachineIp string  `json:"machine_ip"`
@@ -0,0 +1,64 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Priority classes of the jobs, higher priorities are served first. Any other integer is a valid priority as well.
+const (
+	PriorityLow    = -1
+	PriorityNormal = 0
+	PriorityHigh   = 1
+)
+
+var priorityNames = map[int]string{
+	PriorityLow:    "low",
+	PriorityNormal: "normal",
+	PriorityHigh:   "high",
+}
+
+// ParsePriority parses a priority given by the name of its class or as an integer
+func ParsePriority(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	for priority, name := range priorityNames {
+		if strings.EqualFold(value, name) {
+			return priority, nil
+		}
+	}
+	priority, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("priority must be low, normal, high or an integer: %s", value)
+	}
+	return priority, nil
+}
+
+// GetPriorityClass returns the name of the class of the priority, priorities below low or above high belong to those
+// classes. It is bounded, so it can be used as a metric label.
+func GetPriorityClass(priority int) string {
+	if priority < PriorityLow {
+		priority = PriorityLow
+	} else if priority > PriorityHigh {
+		priority = PriorityHigh
+	}
+	return priorityNames[priority]
+}
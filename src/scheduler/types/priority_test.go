@@ -0,0 +1,76 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import "testing"
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+		fails    bool
+	}{
+		{value: "low", expected: PriorityLow},
+		{value: "normal", expected: PriorityNormal},
+		{value: "High", expected: PriorityHigh},
+		{value: " high ", expected: PriorityHigh},
+		{value: "5", expected: 5},
+		{value: "-3", expected: -3},
+		{value: "urgent", fails: true},
+		{value: "1.5", fails: true},
+		{value: "", fails: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			priority, err := ParsePriority(test.value)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected an error, got %d", priority)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			if priority != test.expected {
+				t.Fatalf("expected %d, got %d", test.expected, priority)
+			}
+		})
+	}
+}
+
+func TestGetPriorityClass(t *testing.T) {
+	tests := []struct {
+		priority int
+		expected string
+	}{
+		{priority: -10, expected: "low"},
+		{priority: PriorityLow, expected: "low"},
+		{priority: PriorityNormal, expected: "normal"},
+		{priority: PriorityHigh, expected: "high"},
+		{priority: 10, expected: "high"},
+	}
+
+	for _, test := range tests {
+		if class := GetPriorityClass(test.priority); class != test.expected {
+			t.Errorf("expected class %s for priority %d, got %s", test.expected, test.priority, class)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ * P2PFaaS - A framework for FaaS Load Balancing
+ * Copyright (c) 2019. Gabriele Proietti Mattia <pm.gabriele@outlook.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package types
+
+// Ways the local queue is split in sub-queues, which are served by deficit round-robin
+const (
+	QueueFairnessNone     = "none"     // a single sub-queue, jobs are served in order of priority and arrival
+	QueueFairnessFunction = "function" // a sub-queue for every function
+	QueueFairnessTenant   = "tenant"   // a sub-queue for every tenant, jobs without a tenant share the default one
+)
+
+type QueueConfiguration struct {
+	Fairness      string          `json:"fairness"`       // how the queue is split in sub-queues: none, function or tenant
+	DefaultWeight uint            `json:"default_weight"` // weight of the sub-queues not listed in Weights
+	DefaultCap    uint            `json:"default_cap"`    // maximum jobs of the sub-queues not listed in Caps, 0 for no cap
+	Weights       map[string]uint `json:"weights"`        // weight of the sub-queues by function or tenant
+	Caps          map[string]uint `json:"caps"`           // maximum jobs of the sub-queues by function or tenant
+}
+
+type SubQueueStatus struct {
+	Key      string `json:"key"`      // function or tenant of the sub-queue, empty for the default one
+	Weight   uint   `json:"weight"`   // jobs served in a round when the sub-queue has jobs waiting
+	Cap      uint   `json:"cap"`      // maximum jobs waiting in the sub-queue, 0 if it has no cap
+	Fill     int    `json:"fill"`     // jobs waiting in the sub-queue
+	Deficit  uint   `json:"deficit"`  // jobs the sub-queue can still be served in the current round
+	Enqueued uint64 `json:"enqueued"` // jobs enqueued in the sub-queue
+	Rejected uint64 `json:"rejected"` // jobs rejected because the sub-queue was full
+}
+
+type QueueStatus struct {
+	Fairness  string           `json:"fairness"`   // how the queue is split in sub-queues
+	Fill      int              `json:"fill"`       // jobs waiting in the queue
+	LengthMax uint             `json:"length_max"` // maximum jobs waiting in the whole queue
+	SubQueues []SubQueueStatus `json:"sub_queues"` // the sub-queues which had jobs, sorted by key
+}
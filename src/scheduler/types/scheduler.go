@@ -1,6 +1,210 @@
 package types
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
 type SchedulerDescriptor struct {
-	Name       string   `json:"name"`
-	Parameters []string `json:"parameters"`
+	Name       string                 `json:"name"`
+	Parameters map[string]interface{} `json:"parameters"`
+	// PositionalParameters is filled when the descriptor is decoded from the old format, in which parameters were a list
+	// of strings. They are named by the scheduler registry by following the order of the scheduler parameters.
+	PositionalParameters []string `json:"-"`
+	// State is the runtime state of the scheduler, like values tuned online, it is ignored when the descriptor is decoded
+	State map[string]interface{} `json:"state,omitempty"`
+}
+
+// UnmarshalJSON decodes the descriptor accepting both the named parameters object and the old positional list
+func (d *SchedulerDescriptor) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Name       string          `json:"name"`
+		Parameters json.RawMessage `json:"parameters"`
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	d.Name = raw.Name
+	d.Parameters = nil
+	d.PositionalParameters = nil
+	d.State = nil
+
+	parameters := bytes.TrimSpace(raw.Parameters)
+	if len(parameters) == 0 || bytes.Equal(parameters, []byte("null")) {
+		return nil
+	}
+	if parameters[0] == '[' {
+		return json.Unmarshal(parameters, &d.PositionalParameters)
+	}
+	return json.Unmarshal(parameters, &d.Parameters)
+}
+
+// SchedulerInfo describes a scheduler that can be selected, with the documentation of its parameters
+type SchedulerInfo struct {
+	Name        string                   `json:"name"`
+	Description string                   `json:"description"`
+	Parameters  []SchedulerParameterInfo `json:"parameters"`
+}
+
+type SchedulerParameterInfo struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Description string      `json:"description"`
+	Default     interface{} `json:"default,omitempty"` // if nil the parameter is required
+	Required    bool        `json:"required"`
+}
+
+// FunctionScheduler is the scheduler used for the jobs of a function
+type FunctionScheduler struct {
+	Function  string               `json:"function"`
+	Scheduler *SchedulerDescriptor `json:"scheduler"`
+	Default   bool                 `json:"default"` // the function has no scheduler of its own and uses the global one
+}
+
+// LearningTable is the policy learned by a learning scheduler, it can be exported and imported for warm starts
+type LearningTable struct {
+	Scheduler string                              `json:"scheduler"` // full name of the scheduler that learned it
+	Entries   map[string]map[string]LearningEntry `json:"entries"`   // state -> action -> entry
+}
+
+type LearningEntry struct {
+	Value  float64 `json:"value"`  // estimated reward of taking the action in the state
+	Visits uint64  `json:"visits"` // times the action has been taken in the state
+}
+
+// DecisionSnapshot is the state of the node sent to an external decision service for deciding where to execute a job
+type DecisionSnapshot struct {
+	RequestId  uint64              `json:"request_id"`
+	Function   string              `json:"function"`
+	External   bool                `json:"external"` // the job comes from another node
+	Hops       int                 `json:"hops"`     // number of times the job has been forwarded
+	Load       uint                `json:"load"`     // running functions of the node
+	MaxLoad    uint                `json:"max_load"` // maximum running functions of the node
+	QueueFill  int                 `json:"queue_fill"`
+	QueueMax   uint                `json:"queue_max"`
+	Candidates []DecisionCandidate `json:"candidates"` // peers to which the job can be forwarded
+}
+
+type DecisionCandidate struct {
+	Ip        string `json:"ip"`
+	Load      uint   `json:"load"`
+	MaxLoad   uint   `json:"max_load"`  // 0 if not known
+	Reachable bool   `json:"reachable"` // false if the load cannot be probed
+}
+
+// DecisionTrace records how a job has been scheduled. Its methods can be called on a nil trace, which records nothing.
+type DecisionTrace struct {
+	DecisionRecord
+	mutex sync.Mutex
+}
+
+// DecisionRecord is the content of a decision trace
+type DecisionRecord struct {
+	RequestId uint64              `json:"request_id"`
+	Function  string              `json:"function"`
+	Scheduler string              `json:"scheduler"`
+	External  bool                `json:"external"` // the job comes from another node
+	Hops      int                 `json:"hops"`     // number of times the job has been forwarded
+	Load      uint                `json:"load"`     // running functions of the node when the job arrived
+	Checks    []DecisionCheck     `json:"checks"`   // conditions checked by the scheduler, in order
+	Probes    []DecisionCandidate `json:"probes"`   // peers probed and their loads
+	Target    string              `json:"target"`   // local, drop or forward:<ip>
+	Reason    string              `json:"reason,omitempty"`
+	Error     string              `json:"error,omitempty"`
+	StartedAt time.Time           `json:"started_at"`
+	Duration  float64             `json:"duration"` // seconds taken for scheduling and executing the job
+}
+
+type DecisionCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// AddCheck records a condition checked by the scheduler, detail is formatted with the arguments
+func (t *DecisionTrace) AddCheck(name string, passed bool, detail string, args ...interface{}) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.Checks = append(t.Checks, DecisionCheck{Name: name, Passed: passed, Detail: fmt.Sprintf(detail, args...)})
+}
+
+// AddProbe records a probed peer, reachable is false if it could not be probed
+func (t *DecisionTrace) AddProbe(ip string, running uint, max uint, reachable bool) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.Probes = append(t.Probes, DecisionCandidate{Ip: ip, Load: running, MaxLoad: max, Reachable: reachable})
+}
+
+// SetReason records why the job has been scheduled to its target, replacing the previous reason
+func (t *DecisionTrace) SetReason(reason string, args ...interface{}) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.Reason = fmt.Sprintf(reason, args...)
+}
+
+// Finish records the target of the job and the error returned by the scheduler, if any
+func (t *DecisionTrace) Finish(target string, err error) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.Target = target
+	if err != nil {
+		t.Error = err.Error()
+	}
+	t.Duration = time.Since(t.StartedAt).Seconds()
+}
+
+// MarshalJSON encodes the trace holding its lock, since copies of the job may still be recording in it
+func (t *DecisionTrace) MarshalJSON() ([]byte, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return json.Marshal(t.DecisionRecord)
+}
+
+// ShadowStatus reports the scheduler evaluated in shadow mode next to the active one
+type ShadowStatus struct {
+	Scheduler  *SchedulerDescriptor `json:"scheduler"`
+	FullName   string               `json:"full_name"`
+	Probe      bool                 `json:"probe"`      // the shadow scheduler probes peers, otherwise it uses their last known loads
+	Since      time.Time            `json:"since"`      // time at which the shadow scheduler has been set
+	Decisions  uint64               `json:"decisions"`  // number of jobs decided by both schedulers
+	Agreements uint64               `json:"agreements"` // number of jobs for which both schedulers took the same decision
+}
+
+// ShadowDecision is the pair of decisions taken for a job by the active and the shadow schedulers
+type ShadowDecision struct {
+	RequestId uint64    `json:"request_id"`
+	Function  string    `json:"function"`
+	Active    string    `json:"active"`
+	Shadow    string    `json:"shadow"`
+	Agree     bool      `json:"agree"`
+	At        time.Time `json:"at"`
+}
+
+// SchedulerSwapStatus reports the state of the last drain-and-swap of the scheduler
+type SchedulerSwapStatus struct {
+	OldScheduler string     `json:"old_scheduler"`
+	NewScheduler string     `json:"new_scheduler"`
+	SwappedAt    time.Time  `json:"swapped_at"`
+	InFlight     int64      `json:"in_flight"` // Schedule calls still running on the old scheduler
+	Drained      bool       `json:"drained"`
+	DrainedAt    *time.Time `json:"drained_at,omitempty"`
+	Timeout      string     `json:"timeout,omitempty"`
+	TimedOut     bool       `json:"timed_out"`
 }
@@ -18,11 +18,25 @@
 
 package types
 
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
 type ServiceRequest struct {
-	Id                 uint64 // unique id assigned to the request
-	ServiceName        string // Name of the function to be executed
+	Id                 uint64          // unique id assigned to the request
+	Context            context.Context // Context of the client request, the job is abandoned when it is done
+	ServiceName        string          // Name of the function to be executed
 	Payload            []byte
 	ContentType        string
 	External           bool // If the service request comes from another node and not user
 	ExternalJobRequest *PeerJobRequest
+	Deadline           *time.Time     // Time by which the job should be completed, nil if it has no deadline
+	Key                string         // Key of the request for scheduling affinity, empty if it has none
+	Priority           int            // Priority of the job, higher priorities are served first
+	Tenant             string         // Tenant the job belongs to, empty if it has none
+	Headers            http.Header    // Headers of the client request, nil if the request comes from another node
+	DryRun             bool           // If the job is only scheduled for evaluating a scheduler, it is never executed
+	Trace              *DecisionTrace // Trace of the scheduling decisions, nil if they are not recorded
 }
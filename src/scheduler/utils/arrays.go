@@ -73,3 +73,46 @@ func LoadsBelowSpecificLoad(slots []uint, threshold uint) []uint {
 	}
 	return loadsBelow
 }
+
+func MinOfArrayFloat(array []float64) (float64, int) {
+	minValue := array[0]
+	minIndex := 0
+	for i, v := range array {
+		if v < minValue {
+			minIndex = i
+			minValue = v
+		}
+	}
+	return minValue, minIndex
+}
+
+func LoadsBelowSpecificLoadFloat(slots []float64, threshold float64) []uint {
+	var loadsBelow []uint
+	for i, v := range slots {
+		if v <= threshold {
+			loadsBelow = append(loadsBelow, uint(i))
+		}
+	}
+	return loadsBelow
+}
+
+// StringInArray tells if the string is one of the array
+func StringInArray(value string, array []string) bool {
+	for _, item := range array {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveStringFromArray returns a copy of the array without the occurrences of the string
+func RemoveStringFromArray(value string, array []string) []string {
+	var out []string
+	for _, item := range array {
+		if item != value {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -20,9 +20,13 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"net"
 	"net/http"
 	"scheduler/log"
+	"strings"
+	"time"
 )
 
 type ErrorHttpCannotCreateRequest struct{}
@@ -51,6 +55,36 @@ func HttpPostJSON(url string, json string) (*http.Response, error) {
 	return res, err
 }
 
+// HttpPostJSONWithTimeout is like HttpPostJSON but the request must complete within the timeout. An url like
+// unix:/path/to.sock posts to the unix socket at that path.
+func HttpPostJSONWithTimeout(url string, json string, timeout time.Duration) (*http.Response, error) {
+	transport := httpTransport
+	if strings.HasPrefix(url, "unix:") {
+		socket := strings.TrimPrefix(strings.TrimPrefix(url, "unix:"), "//")
+		transport = &http.Transport{
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		}
+		url = "http://unix/"
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBufferString(json))
+	if req == nil {
+		return nil, ErrorHttpCannotCreateRequest{}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Transport: transport, Timeout: timeout}
+	res, err := client.Do(req)
+	if err != nil {
+		log.Log.Debugf("Cannot POST to %s: %s", url, err.Error())
+	}
+
+	return res, err
+}
+
 func HttpGet(url string) (*http.Response, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if req == nil {
@@ -85,7 +119,12 @@ func HttpMachineGet(url string) (*http.Response, error) {
 }
 
 func HttpMachinePostJSON(url string, json string) (*http.Response, error) {
-	req, err := http.NewRequest("POST", url, bytes.NewBufferString(json))
+	return HttpMachinePostJSONWithContext(context.Background(), url, json)
+}
+
+// HttpMachinePostJSONWithContext is like HttpMachinePostJSON but the request is aborted when the context is done
+func HttpMachinePostJSONWithContext(ctx context.Context, url string, json string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBufferString(json))
 	if req == nil {
 		return nil, ErrorHttpCannotCreateRequest{}
 	}
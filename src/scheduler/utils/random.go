@@ -31,3 +31,10 @@ func GetRandomInteger(max int) int {
 	randomGenerator := rand.New(randomSource)
 	return randomGenerator.Int() % max
 }
+
+// GetRandomFloat returns a random number in [0, 1)
+func GetRandomFloat() float64 {
+	randomSource := rand.NewSource(time.Now().UnixNano())
+	randomGenerator := rand.New(randomSource)
+	return randomGenerator.Float64()
+}
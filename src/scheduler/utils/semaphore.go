@@ -18,6 +18,8 @@
 
 package utils
 
+import "context"
+
 /*
  * From http://www.golangpatterns.info/concurrency/semaphores
  */
@@ -59,3 +61,23 @@ func (s Semaphore) Signal() {
 func (s Semaphore) Wait(n int) {
 	s.P(n)
 }
+
+// TryWait acquires one resource only if it is immediately available, it returns true if it has been acquired
+func (s Semaphore) TryWait() bool {
+	select {
+	case s <- empty{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// WaitContext acquires one resource waiting at most until the context is done, it returns true if it has been acquired
+func (s Semaphore) WaitContext(ctx context.Context) bool {
+	select {
+	case s <- empty{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}